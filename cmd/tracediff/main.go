@@ -0,0 +1,66 @@
+// tracediff compares an execution trace against a reference trace and
+// reports the first instruction where they disagree. Either file may be a
+// text or binary trace, see the trace package for the text layout.
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "strconv"
+
+    "github.com/siathema/goInvadeSpace/trace"
+)
+
+func main() {
+    opts := trace.DefaultDiffOptions()
+    flag.IntVar(&opts.Context, "context", opts.Context, "instructions to show before the mismatch and after it in each trace")
+    flag.BoolVar(&opts.IgnoreCycles, "nocycles", false, "don't compare cycle counts")
+    mask := flag.String("flagmask", fmt.Sprintf("%02X", opts.FlagMask), "hex mask of flag bits to compare")
+    flag.Usage = func() {
+        fmt.Fprintf(flag.CommandLine.Output(), "usage: tracediff [flags] OURS REFERENCE\n")
+        flag.PrintDefaults()
+    }
+    flag.Parse()
+    if flag.NArg() != 2 {
+        flag.Usage()
+        os.Exit(2)
+    }
+    m, err := strconv.ParseUint(*mask, 16, 8)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "tracediff: bad -flagmask %q\n", *mask)
+        os.Exit(2)
+    }
+    opts.FlagMask = uint8(m)
+
+    ours, err := open(flag.Arg(0))
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "tracediff:", err)
+        os.Exit(2)
+    }
+    ref, err := open(flag.Arg(1))
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "tracediff:", err)
+        os.Exit(2)
+    }
+
+    mismatch, err := trace.Diff(ours, ref, opts)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "tracediff:", err)
+        os.Exit(2)
+    }
+    if mismatch != nil {
+        mismatch.Report(os.Stdout)
+        os.Exit(1)
+    }
+    fmt.Println("traces match")
+}
+
+func open(path string) (trace.EntryReader, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    // Left open until exit.
+    return trace.NewReader(f)
+}
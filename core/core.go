@@ -1,37 +1,67 @@
 package core
 
 import (
-    "fmt"
+    "math/bits"
 
     "github.com/siathema/goInvadeSpace/memory"
 )
 
+// Flag bits of the status byte. Bit 1 always reads as 1, bits 3 and 5 as 0.
+const (
+    FlagS  uint8 = 0x80
+    FlagZ  uint8 = 0x40
+    FlagAC uint8 = 0x10
+    FlagP  uint8 = 0x04
+    FlagCY uint8 = 0x01
+)
+
 type Core8080 struct {
     A, B, C, D, E, H, L, Flags uint8
     SP, PC uint16
     Irq, Write, Sync bool
+    // IntEnable is set by EI and cleared by DI, Halted by HLT.
+    IntEnable, Halted bool
     cycles uint64
+    // Bytes of the instruction being executed.
+    op [3]uint8
+
+    // IO serves IN and OUT. Without one IN leaves A alone and OUT goes
+    // nowhere.
+    IO IO
+    // Tracer, when set, sees every instruction before it executes.
+    Tracer Tracer
+    event TraceEvent
+}
+
+// IO is the 8080's port address space.
+type IO interface {
+    In(port uint8) uint8
+    Out(port uint8, data uint8)
 }
 
 func New() *Core8080 {
     c := &Core8080{
         A: 0, B: 0, C: 0, D: 0, E: 0, H: 0, L: 0,
-        Flags: 0x00, Irq: false, Write: false, Sync: false,
+        Flags: 0x02, Irq: false, Write: false, Sync: false,
         cycles: 0,
     }
 
     return c
 }
 
-func (core *Core8080) RunTick(mem *memory.MainMemory) {
-    // Read opcode from memory
+func (core *Core8080) RunTick(mem memory.Memory) {
+    // Read opcode from memory, operands only as far as the instruction
+    // goes. Traces record the whole 3 byte window.
     core.Write = false
-    opcode := make([]uint8, 3)
-    for i :=0; i < 3; i++ {
-        opcode[i] = mem.Read(core.PC + uint16(i))
+    core.op[0] = mem.Read(core.PC)
+    n := Opcodes[core.op[0]].Size
+    if core.Tracer != nil {
+        n = 3
     }
-    core.ExecuteOpcode(opcode, mem)
-
+    for i := 1; i < n; i++ {
+        core.op[i] = mem.Read(core.PC + uint16(i))
+    }
+    core.execute(mem)
 }
 
 func u8HiLowRoU16(hi uint8, low uint8) uint16 {
@@ -46,973 +76,1064 @@ func u16ToHiLowU8(n uint16) (uint8, uint8) {
     return h, l 
 }
 
-func (core *Core8080) UpdateFlags(after uint8, carry bool) {
-    // Zero flag
-    if(after == 0) {
-        core.Flags |= 0x40
+func (core *Core8080) BC() uint16 {
+    return uint16(core.B)<<8 | uint16(core.C)
+}
+
+func (core *Core8080) DE() uint16 {
+    return uint16(core.D)<<8 | uint16(core.E)
+}
+
+func (core *Core8080) HL() uint16 {
+    return uint16(core.H)<<8 | uint16(core.L)
+}
+
+func (core *Core8080) SetBC(v uint16) {
+    core.B, core.C = uint8(v>>8), uint8(v)
+}
+
+func (core *Core8080) SetDE(v uint16) {
+    core.D, core.E = uint8(v>>8), uint8(v)
+}
+
+func (core *Core8080) SetHL(v uint16) {
+    core.H, core.L = uint8(v>>8), uint8(v)
+}
+
+// imm16 is the little endian word operand of the current instruction.
+func (core *Core8080) imm16() uint16 {
+    return uint16(core.op[2])<<8 | uint16(core.op[1])
+}
+
+func (core *Core8080) flag(bit uint8) bool {
+    return core.Flags&bit != 0
+}
+
+func (core *Core8080) setFlag(bit uint8, on bool) {
+    if on {
+        core.Flags |= bit
     } else {
-        core.Flags &= 0xBF
+        core.Flags &^= bit
     }
-    // Negative flag
-    if((after & 0x80) != 0) {
-        core.Flags |= 0x80
-    } else {
-        core.Flags &= 0x70
+}
+
+// Zero, sign and parity all come straight from the result.
+func (core *Core8080) updateZSP(after uint8) {
+    core.setFlag(FlagZ, after == 0)
+    core.setFlag(FlagS, after&0x80 != 0)
+    core.setFlag(FlagP, bits.OnesCount8(after)%2 == 0)
+}
+
+func (core *Core8080) UpdateFlags(after uint8, carry bool) {
+    core.updateZSP(after)
+    core.setFlag(FlagCY, carry)
+}
+
+func (core *Core8080) readM(mem memory.Memory) uint8 {
+    return mem.Read(core.HL())
+}
+
+func (core *Core8080) writeM(mem memory.Memory, data uint8) {
+    mem.Write(core.HL(), data)
+}
+
+func (core *Core8080) inr(v uint8) uint8 {
+    after := v + 1
+    core.updateZSP(after)
+    core.setFlag(FlagAC, after&0x0F == 0)
+    return after
+}
+
+func (core *Core8080) dcr(v uint8) uint8 {
+    after := v - 1
+    core.updateZSP(after)
+    core.setFlag(FlagAC, after&0x0F != 0x0F)
+    return after
+}
+
+// A <- A + v + carry. AC is the carry out of bit 3.
+func (core *Core8080) add(v uint8, carry bool) {
+    c := uint16(0)
+    if carry {
+        c = 1
     }
-    // Parity flag
-    p := 0
-    for i := 0; i < 8; i++ {
-        if((after >> i) & 0x01 != 0) {
-            p++
-        }
+    sum := uint16(core.A) + uint16(v) + c
+    core.setFlag(FlagAC, (uint16(core.A)^uint16(v)^sum)&0x10 != 0)
+    core.A = uint8(sum)
+    core.UpdateFlags(core.A, sum > 0xFF)
+}
+
+// A <- A - v - borrow. The 8080 subtracts by adding the complement, so AC
+// is the carry out of bit 3 of that addition and CY is the inverted carry.
+func (core *Core8080) sub(v uint8, borrow bool) {
+    core.add(^v, !borrow)
+    core.setFlag(FlagCY, !core.flag(FlagCY))
+}
+
+func (core *Core8080) cmp(v uint8) {
+    a := core.A
+    core.sub(v, false)
+    core.A = a
+}
+
+// ANA clears CY and sets AC from bit 3 of either operand.
+func (core *Core8080) ana(v uint8) {
+    core.setFlag(FlagAC, (core.A|v)&0x08 != 0)
+    core.A &= v
+    core.UpdateFlags(core.A, false)
+}
+
+func (core *Core8080) xra(v uint8) {
+    core.A ^= v
+    core.UpdateFlags(core.A, false)
+    core.setFlag(FlagAC, false)
+}
+
+func (core *Core8080) ora(v uint8) {
+    core.A |= v
+    core.UpdateFlags(core.A, false)
+    core.setFlag(FlagAC, false)
+}
+
+func (core *Core8080) daa() {
+    correction := uint8(0)
+    carry := core.flag(FlagCY)
+    lsb, msb := core.A&0x0F, core.A>>4
+    if core.flag(FlagAC) || lsb > 9 {
+        correction |= 0x06
     }
-    if (p % 2 == 0) {
-        core.Flags |= 0x04
-    } else {
-        core.Flags &= 0xfb
+    if carry || msb > 9 || (msb >= 9 && lsb > 9) {
+        correction |= 0x60
+        carry = true
     }
-    //carry
-    if carry {
-        core.Flags |= 0x01
-    } else {
-        core.Flags &= 0xFE
+    core.add(correction, false)
+    core.setFlag(FlagCY, carry)
+}
+
+func (core *Core8080) dad(v uint16) {
+    sum := uint32(core.HL()) + uint32(v)
+    core.SetHL(uint16(sum))
+    core.setFlag(FlagCY, sum > 0xFFFF)
+}
+
+func (core *Core8080) push(mem memory.Memory, v uint16) {
+    hi, lo := u16ToHiLowU8(v)
+    mem.Write(core.SP-1, hi)
+    mem.Write(core.SP-2, lo)
+    core.SP -= 2
+}
+
+func (core *Core8080) pop(mem memory.Memory) uint16 {
+    v := u8HiLowRoU16(mem.Read(core.SP+1), mem.Read(core.SP))
+    core.SP += 2
+    return v
+}
+
+// call pushes the address of the next instruction and jumps to addr.
+func (core *Core8080) call(mem memory.Memory, addr uint16) {
+    core.push(mem, core.PC)
+    core.PC = addr
+}
+
+func (core *Core8080) ret(mem memory.Memory) {
+    core.PC = core.pop(mem)
+}
+
+// Interrupt puts RST n on the bus. It is ignored unless interrupts are
+// enabled, taking one disables them and wakes a halted CPU. Reports whether
+// the interrupt was taken.
+func (core *Core8080) Interrupt(n uint8, mem memory.Memory) bool {
+    if !core.IntEnable {
+        return false
     }
+    core.IntEnable = false
+    core.Halted = false
+    core.cycles += uint64(Opcodes[0xc7].Cycles)
+    core.call(mem, uint16(n&0x07)*8)
+
+    return true
 }
 
-func (core *Core8080) ExecuteOpcode(opcode []uint8, mem *memory.MainMemory) {
-    switch opcode[0] {
-    case 0x00: 	   //NOP	1		
-        fmt.Printf("NOP\n")
-        core.PC++
-    case 0x01://LXI B,D16	3		B <- byte 3, C <- byte 2
-        fmt.Printf("LXI B,D16\n")
-        core.B = opcode[2]
-        core.C = opcode[1]
-        core.PC += 3
-    case 0x02://STAX B	1		(BC) <- A
-        core.PC++
-        addr := uint16(core.B) << 8
-        addr |= uint16(core.C)
-        mem.Write(addr, core.A)
-        fmt.Printf("STAX B\n")
-    case 0x03://INX B	1		BC <- BC+1
-        core.PC++
-        item := u8HiLowRoU16(core.B, core.C)
-        item++
-        core.C, core.B = u16ToHiLowU8(item)
-        fmt.Printf("INX B\n")
-    case 0x04://INR B	1	Z, S, P, AC	B <- B+1
-        fmt.Printf("INR B\n")
-        core.PC++
-    case 0x05://DCR B	1	Z, S, P, AC	B <- B-1
-        fmt.Printf("DCR B\n")
-        core.PC++
-    case 0x06://MVI B, D8	2		B <- byte 2
-        fmt.Printf("MVI B\n")
-        core.B = opcode[1]
-        core.PC += 2
-    case 0x07://RLC	1	CY	A = A << 1; bit 0 = prev bit 7; CY = prev bit 7
-        fmt.Printf("RLC\n")
-        core.PC++
-    case 0x08://-			
-        fmt.Printf("Invalid Instruction\n")
-        core.PC++
-    case 0x09://DAD B	1	CY	HL = HL + BC
-        fmt.Printf("DAD B\n")
-        core.PC++
-    case 0x0a://LDAX B	1		A <- (BC)
-        fmt.Printf("LDAX B\n")
-        addr := uint16(core.B) << 8
-        addr |= uint16(core.C)
-        core.A = mem.Read(addr)
-        core.PC++
-    case 0x0b://DCX B	1		BC = BC-1
-        fmt.Printf("DCX B\n")
-        item := uint16(core.B) << 8
-        item |= uint16(core.C)
-        item--
-        core.C = uint8(item)
-        core.B = uint8(item >> 8)
-        core.PC++
-    case 0x0c://INR C	1	Z, S, P, AC	C <- C+1
-        fmt.Printf("INR C\n")
-        temp := core.C + 1
-        carry := temp < core.C
-        core.UpdateFlags(temp, carry)
-        core.C = temp 
-        core.PC++
-    case 0x0d://DCR C	1	Z, S, P, AC	C <-C-1
-        fmt.Printf("DCR C\n")
-        temp := core.C - 1
-        carry := temp > core.C
-        core.UpdateFlags(temp, carry)
-        core.C = temp 
-        core.PC++
-    case 0x0e://MVI C,D8	2		C <- byte 2
-        fmt.Printf("MVI C, D8\n")
-        core.C = opcode[1]
-        core.PC += 2
-    case 0x0f://RRC	1	CY	A = A >> 1; bit 7 = prev bit 0; CY = prev bit 0
-        fmt.Printf("RRC\n")
-        carry := (core.A & 0x01) != 0
-        core.A >>= 1
-        if carry {
-            core.A |= 0x80
-            core.Flags |= 0x01
-        } else {
-            core.Flags &= 0xFE
-        } 
-        core.PC++
-    case 0x10://-			
-        fmt.Printf("Invalid Instruction\n")
-        core.PC++
-    case 0x11://LXI D,D16	3		D <- byte 3, E <- byte 2
-        fmt.Printf("LXI D, D16\n")
-        core.D = opcode[1]
-        core.E = opcode[2]
-        core.PC += 3
-    case 0x12://STAX D	1		(DE) <- A
-        fmt.Printf("STAX D\n")
-        addr := uint16(core.D) << 8
-        addr |= uint16(core.E)
-        mem.Write(addr, core.A)
-        core.PC++
-    case 0x13://INX D	1		DE <- DE + 1
-        fmt.Printf("INX D\n")
-        temp := uint16(core.D) << 8
-        temp |= uint16(core.E)
-        temp++
-        core.E = uint8(core.E)
-        core.D = uint8(core.D >> 8)
-        core.PC++
-    case 0x14://INR D	1	Z, S, P, AC	D <- D+1
-        fmt.Printf("INR D\n")
-        temp := core.D + 1
-        carry := temp < core.D
-        core.UpdateFlags(temp, carry)
-        core.D = temp 
-        core.PC++
-    case 0x15://DCR D	1	Z, S, P, AC	D <- D-1
-        fmt.Printf("DCR D\n")
-        temp := core.D - 1
-        carry := temp > core.D
-        core.UpdateFlags(temp, carry)
-        core.D = temp 
-        core.PC++
-    case 0x16://MVI D, D8	2		D <- byte 2
-        fmt.Printf("MVI D, D8\n")
-        core.D = opcode[1]
-        core.PC += 2
-    case 0x17://RAL	1	CY	A = A << 1; bit 0 = prev CY; CY = prev bit 7
-        fmt.Printf("RAL\n")
-        carry := (core.A & 0x80) != 0
+// Cycles is the number of clock cycles run since New.
+func (core *Core8080) Cycles() uint64 {
+    return core.cycles
+}
+
+// SetCycles rewinds or advances the cycle counter, for restoring a saved
+// machine.
+func (core *Core8080) SetCycles(n uint64) {
+    core.cycles = n
+}
+
+// ExecuteOpcode runs the instruction in opcode, which holds at least as
+// many bytes as the instruction is long. PC must point at it.
+func (core *Core8080) ExecuteOpcode(opcode []uint8, mem memory.Memory) {
+    copy(core.op[:], opcode)
+    core.execute(mem)
+}
+
+func (core *Core8080) execute(mem memory.Memory) {
+    // A halted CPU idles until an interrupt comes along.
+    if core.Halted {
+        core.cycles += 4
+        return
+    }
+    if core.Tracer != nil {
+        core.trace()
+    }
+    info := &Opcodes[core.op[0]]
+    core.cycles += uint64(info.Cycles)
+    core.PC += uint16(info.Size)
+    dispatch[core.op[0]](core, mem)
+}
+
+// A handler carries out one opcode. PC already points at the next
+// instruction when it runs and the operand bytes are in core.op.
+type handler func(core *Core8080, mem memory.Memory)
+
+var dispatch = [256]handler{
+    0x00: func(core *Core8080, mem memory.Memory) { //NOP	1
+    },
+    0x01: func(core *Core8080, mem memory.Memory) { //LXI B,D16	3		B <- byte 3, C <- byte 2
+        core.SetBC(core.imm16())
+    },
+    0x02: func(core *Core8080, mem memory.Memory) { //STAX B	1		(BC) <- A
+        mem.Write(core.BC(), core.A)
+    },
+    0x03: func(core *Core8080, mem memory.Memory) { //INX B	1		BC <- BC+1
+        core.SetBC(core.BC() + 1)
+    },
+    0x04: func(core *Core8080, mem memory.Memory) { //INR B	1	Z, S, P, AC	B <- B+1
+        core.B = core.inr(core.B)
+    },
+    0x05: func(core *Core8080, mem memory.Memory) { //DCR B	1	Z, S, P, AC	B <- B-1
+        core.B = core.dcr(core.B)
+    },
+    0x06: func(core *Core8080, mem memory.Memory) { //MVI B, D8	2		B <- byte 2
+        core.B = core.op[1]
+    },
+    0x07: func(core *Core8080, mem memory.Memory) { //RLC	1	CY	A = A << 1; bit 0 = prev bit 7; CY = prev bit 7
+        carry := core.A&0x80 != 0
+        core.A = core.A<<1 | core.A>>7
+        core.setFlag(FlagCY, carry)
+    },
+    0x08: func(core *Core8080, mem memory.Memory) { //-
+    },
+    0x09: func(core *Core8080, mem memory.Memory) { //DAD B	1	CY	HL = HL + BC
+        core.dad(core.BC())
+    },
+    0x0a: func(core *Core8080, mem memory.Memory) { //LDAX B	1		A <- (BC)
+        core.A = mem.Read(core.BC())
+    },
+    0x0b: func(core *Core8080, mem memory.Memory) { //DCX B	1		BC = BC-1
+        core.SetBC(core.BC() - 1)
+    },
+    0x0c: func(core *Core8080, mem memory.Memory) { //INR C	1	Z, S, P, AC	C <- C+1
+        core.C = core.inr(core.C)
+    },
+    0x0d: func(core *Core8080, mem memory.Memory) { //DCR C	1	Z, S, P, AC	C <-C-1
+        core.C = core.dcr(core.C)
+    },
+    0x0e: func(core *Core8080, mem memory.Memory) { //MVI C,D8	2		C <- byte 2
+        core.C = core.op[1]
+    },
+    0x0f: func(core *Core8080, mem memory.Memory) { //RRC	1	CY	A = A >> 1; bit 7 = prev bit 0; CY = prev bit 0
+        carry := core.A&0x01 != 0
+        core.A = core.A>>1 | core.A<<7
+        core.setFlag(FlagCY, carry)
+    },
+    0x10: func(core *Core8080, mem memory.Memory) { //-
+    },
+    0x11: func(core *Core8080, mem memory.Memory) { //LXI D,D16	3		D <- byte 3, E <- byte 2
+        core.SetDE(core.imm16())
+    },
+    0x12: func(core *Core8080, mem memory.Memory) { //STAX D	1		(DE) <- A
+        mem.Write(core.DE(), core.A)
+    },
+    0x13: func(core *Core8080, mem memory.Memory) { //INX D	1		DE <- DE + 1
+        core.SetDE(core.DE() + 1)
+    },
+    0x14: func(core *Core8080, mem memory.Memory) { //INR D	1	Z, S, P, AC	D <- D+1
+        core.D = core.inr(core.D)
+    },
+    0x15: func(core *Core8080, mem memory.Memory) { //DCR D	1	Z, S, P, AC	D <- D-1
+        core.D = core.dcr(core.D)
+    },
+    0x16: func(core *Core8080, mem memory.Memory) { //MVI D, D8	2		D <- byte 2
+        core.D = core.op[1]
+    },
+    0x17: func(core *Core8080, mem memory.Memory) { //RAL	1	CY	A = A << 1; bit 0 = prev CY; CY = prev bit 7
+        carry := core.A&0x80 != 0
         core.A <<= 1
-        if carry {
-            core.A |= 0x80
-            core.Flags |= 0x01
-        } else {
-            core.Flags &= 0xFE
-        } 
-        core.PC++
-    case 0x18://-			
-        fmt.Printf("Invalid Instruction\n")
-        core.PC++
-    case 0x19://DAD D	1	CY	HL = HL + DE
-        fmt.Printf("DAD D\n")
-        temp1 := uint16(core.H) << 8
-        temp1 |= uint16(core.L)
-        temp2 := uint16(core.D) << 8
-        temp2 |= uint16(core.E)
-        temp3 := temp1 + temp2
-        carry := temp3 <= temp1 || temp3 <= temp2
-        if carry {
+        if core.flag(FlagCY) {
+            core.A |= 0x01
+        }
+        core.setFlag(FlagCY, carry)
+    },
+    0x18: func(core *Core8080, mem memory.Memory) { //-
+    },
+    0x19: func(core *Core8080, mem memory.Memory) { //DAD D	1	CY	HL = HL + DE
+        core.dad(core.DE())
+    },
+    0x1a: func(core *Core8080, mem memory.Memory) { //LDAX D	1		A <- (DE)
+        core.A = mem.Read(core.DE())
+    },
+    0x1b: func(core *Core8080, mem memory.Memory) { //DCX D	1		DE = DE-1
+        core.SetDE(core.DE() - 1)
+    },
+    0x1c: func(core *Core8080, mem memory.Memory) { //INR E	1	Z, S, P, AC	E <-E+1
+        core.E = core.inr(core.E)
+    },
+    0x1d: func(core *Core8080, mem memory.Memory) { //DCR E	1	Z, S, P, AC	E <- E-1
+        core.E = core.dcr(core.E)
+    },
+    0x1e: func(core *Core8080, mem memory.Memory) { //MVI E,D8	2		E <- byte 2
+        core.E = core.op[1]
+    },
+    0x1f: func(core *Core8080, mem memory.Memory) { //RAR	1	CY	A = A >> 1; bit 7 = prev CY; CY = prev bit 0
+        carry := core.A&0x01 != 0
+        core.A >>= 1
+        if core.flag(FlagCY) {
             core.A |= 0x80
-            core.Flags |= 0x01
-        } else {
-            core.Flags &= 0xFE
-        } 
-        core.L = uint8(temp3)
-        core.H = uint8(temp3 >> 8)
-        core.PC++
-    case 0x1a://LDAX D	1		A <- (DE)
-        fmt.Printf("LDAX D\n")
-        addr := uint16(core.D) << 8
-        addr |= uint16(core.E)
-        core.A = mem.Read(addr)
-        core.PC++
-    case 0x1b://DCX D	1		DE = DE-1
-        fmt.Printf("DCX D\n")
-        core.PC++
-    case 0x1c://INR E	1	Z, S, P, AC	E <-E+1
-        fmt.Printf("INR E\n")
-        core.PC++
-    case 0x1d://DCR E	1	Z, S, P, AC	E <- E-1
-        fmt.Printf("DCR E\n")
-        core.PC++
-    case 0x1e://MVI E,D8	2		E <- byte 2
-        fmt.Printf("MVI E, D8\n")
-        core.PC += 2
-    case 0x1f://RAR	1	CY	A = A >> 1; bit 7 = prev bit 7; CY = prev bit 0
-        fmt.Printf("RAR\n")
-        core.PC++
-    case 0x20://-			
-        fmt.Printf("Invalid Instruction\n")
-        core.PC++
-    case 0x21://LXI H,D16	3		H <- byte 3, L <- byte 2
-        fmt.Printf("LXI H, D16\n")
-        core.PC += 3
-    case 0x22://SHLD adr	3		(adr) <-L; (adr+1)<-H
-        fmt.Printf("SHLD adr\n")
-        core.PC += 3
-    case 0x23://INX H	1		HL <- HL + 1
-        fmt.Printf("INX H\n")
-        core.PC++
-    case 0x24://INR H	1	Z, S, P, AC	H <- H+1
-        fmt.Printf("INR H\n")
-        core.PC++
-    case 0x25://DCR H	1	Z, S, P, AC	H <- H-1
-        fmt.Printf("DCR H\n")
-        core.PC++
-    case 0x26://MVI H,D8	2		H <- byte 2
-        fmt.Printf("MVI H, D8\n")
-        core.PC += 2
-    case 0x27://DAA	1		special
-        fmt.Printf("DAA\n")
-        core.PC++
-    case 0x28://-			
-        fmt.Printf("Invalid Instruction\n")
-        core.PC++
-    case 0x29://DAD H	1	CY	HL = HL + HI
-        fmt.Printf("DAD H\n")
-        core.PC++
-    case 0x2a://LHLD adr	3		L <- (adr); H<-(adr+1)
-        fmt.Printf("LHLD adr\n")
-        core.PC += 3
-    case 0x2b://DCX H	1		HL = HL-1
-        fmt.Printf("DCX H\n")
-        core.PC++
-    case 0x2c://INR L	1	Z, S, P, AC	L <- L+1
-        fmt.Printf("INR L\n")
-        core.PC++
-    case 0x2d://DCR L	1	Z, S, P, AC	L <- L-1
-        fmt.Printf("DCR L\n")
-        core.PC++
-    case 0x2e://MVI L, D8	2		L <- byte 2
-        fmt.Printf("MVI L, D*\n")
-        core.PC += 2
-    case 0x2f://CMA	1		A <- !A
-        fmt.Printf("CMA 1\n")
-        core.PC++
-    case 0x30://-			
-        fmt.Printf("Invalid Instruction\n")
-        core.PC++
-    case 0x31://LXI SP, D16	3		SP.hi <- byte 3, SP.lo <- byte 2
-        fmt.Printf("LXI SP, D16\n")
-        core.PC += 3
-    case 0x32://STA adr	3		(adr) <- A
-        fmt.Printf("STA adr\n")
-        core.PC += 3
-    case 0x33://INX SP	1		SP = SP + 1
-        fmt.Printf("INX SP\n")
-        core.PC++
-    case 0x34://INR M	1	Z, S, P, AC	(HL) <- (HL)+1
-        fmt.Printf("INR M\n")
-        core.PC++
-    case 0x35://DCR M	1	Z, S, P, AC	(HL) <- (HL)-1
-        fmt.Printf("DCR M\n")
-        core.PC++
-    case 0x36://MVI M,D8	2		(HL) <- byte 2
-        fmt.Printf("MVI M, M8\n")
-        core.PC += 2
-    case 0x37://STC	1	CY	CY = 1
-        fmt.Printf("STC\n")
-        core.PC++
-    case 0x38://-			
-        fmt.Printf("Invalid Instruction\n")
-        core.PC++
-    case 0x39://DAD SP	1	CY	HL = HL + SP
-        fmt.Printf("DAD SP\n")
-        core.PC++
-    case 0x3a://LDA adr	3		A <- (adr)
-        fmt.Printf("LDA adr\n")
-        core.PC += 3
-    case 0x3b://DCX SP	1		SP = SP-1
-        fmt.Printf("DCX SP\n")
-        core.PC++
-    case 0x3c://INR A	1	Z, S, P, AC	A <- A+1
-        fmt.Printf("INR A\n")
-        core.PC++
-    case 0x3d://DCR A	1	Z, S, P, AC	A <- A-1
-        fmt.Printf("DCR A\n")
-        core.PC++
-    case 0x3e://MVI A,D8	2		A <- byte 2
-        fmt.Printf("MVI A, D8\n")
-        core.PC += 2
-    case 0x3f://CMC	1	CY	CY=!CY
-        fmt.Printf("CMC\n")
-        core.PC++
-    case 0x40://MOV B,B	1		B <- B
-        fmt.Printf("MOV B, B\n")
-        core.PC++
-    case 0x41://MOV B,C	1		B <- C
-        fmt.Printf("MOV B, C\n")
+        }
+        core.setFlag(FlagCY, carry)
+    },
+    0x20: func(core *Core8080, mem memory.Memory) { //-
+    },
+    0x21: func(core *Core8080, mem memory.Memory) { //LXI H,D16	3		H <- byte 3, L <- byte 2
+        core.SetHL(core.imm16())
+    },
+    0x22: func(core *Core8080, mem memory.Memory) { //SHLD adr	3		(adr) <-L; (adr+1)<-H
+        addr := core.imm16()
+        mem.Write(addr, core.L)
+        mem.Write(addr+1, core.H)
+    },
+    0x23: func(core *Core8080, mem memory.Memory) { //INX H	1		HL <- HL + 1
+        core.SetHL(core.HL() + 1)
+    },
+    0x24: func(core *Core8080, mem memory.Memory) { //INR H	1	Z, S, P, AC	H <- H+1
+        core.H = core.inr(core.H)
+    },
+    0x25: func(core *Core8080, mem memory.Memory) { //DCR H	1	Z, S, P, AC	H <- H-1
+        core.H = core.dcr(core.H)
+    },
+    0x26: func(core *Core8080, mem memory.Memory) { //MVI H,D8	2		H <- byte 2
+        core.H = core.op[1]
+    },
+    0x27: func(core *Core8080, mem memory.Memory) { //DAA	1		special
+        core.daa()
+    },
+    0x28: func(core *Core8080, mem memory.Memory) { //-
+    },
+    0x29: func(core *Core8080, mem memory.Memory) { //DAD H	1	CY	HL = HL + HL
+        core.dad(core.HL())
+    },
+    0x2a: func(core *Core8080, mem memory.Memory) { //LHLD adr	3		L <- (adr); H<-(adr+1)
+        addr := core.imm16()
+        core.L = mem.Read(addr)
+        core.H = mem.Read(addr + 1)
+    },
+    0x2b: func(core *Core8080, mem memory.Memory) { //DCX H	1		HL = HL-1
+        core.SetHL(core.HL() - 1)
+    },
+    0x2c: func(core *Core8080, mem memory.Memory) { //INR L	1	Z, S, P, AC	L <- L+1
+        core.L = core.inr(core.L)
+    },
+    0x2d: func(core *Core8080, mem memory.Memory) { //DCR L	1	Z, S, P, AC	L <- L-1
+        core.L = core.dcr(core.L)
+    },
+    0x2e: func(core *Core8080, mem memory.Memory) { //MVI L, D8	2		L <- byte 2
+        core.L = core.op[1]
+    },
+    0x2f: func(core *Core8080, mem memory.Memory) { //CMA	1		A <- !A
+        core.A = ^core.A
+    },
+    0x30: func(core *Core8080, mem memory.Memory) { //-
+    },
+    0x31: func(core *Core8080, mem memory.Memory) { //LXI SP, D16	3		SP.hi <- byte 3, SP.lo <- byte 2
+        core.SP = core.imm16()
+    },
+    0x32: func(core *Core8080, mem memory.Memory) { //STA adr	3		(adr) <- A
+        mem.Write(core.imm16(), core.A)
+    },
+    0x33: func(core *Core8080, mem memory.Memory) { //INX SP	1		SP = SP + 1
+        core.SP = core.SP + 1
+    },
+    0x34: func(core *Core8080, mem memory.Memory) { //INR M	1	Z, S, P, AC	(HL) <- (HL)+1
+        core.writeM(mem, core.inr(core.readM(mem)))
+    },
+    0x35: func(core *Core8080, mem memory.Memory) { //DCR M	1	Z, S, P, AC	(HL) <- (HL)-1
+        core.writeM(mem, core.dcr(core.readM(mem)))
+    },
+    0x36: func(core *Core8080, mem memory.Memory) { //MVI M,D8	2		(HL) <- byte 2
+        core.writeM(mem, core.op[1])
+    },
+    0x37: func(core *Core8080, mem memory.Memory) { //STC	1	CY	CY = 1
+        core.setFlag(FlagCY, true)
+    },
+    0x38: func(core *Core8080, mem memory.Memory) { //-
+    },
+    0x39: func(core *Core8080, mem memory.Memory) { //DAD SP	1	CY	HL = HL + SP
+        core.dad(core.SP)
+    },
+    0x3a: func(core *Core8080, mem memory.Memory) { //LDA adr	3		A <- (adr)
+        core.A = mem.Read(core.imm16())
+    },
+    0x3b: func(core *Core8080, mem memory.Memory) { //DCX SP	1		SP = SP-1
+        core.SP = core.SP - 1
+    },
+    0x3c: func(core *Core8080, mem memory.Memory) { //INR A	1	Z, S, P, AC	A <- A+1
+        core.A = core.inr(core.A)
+    },
+    0x3d: func(core *Core8080, mem memory.Memory) { //DCR A	1	Z, S, P, AC	A <- A-1
+        core.A = core.dcr(core.A)
+    },
+    0x3e: func(core *Core8080, mem memory.Memory) { //MVI A,D8	2		A <- byte 2
+        core.A = core.op[1]
+    },
+    0x3f: func(core *Core8080, mem memory.Memory) { //CMC	1	CY	CY=!CY
+        core.setFlag(FlagCY, !core.flag(FlagCY))
+    },
+    0x40: func(core *Core8080, mem memory.Memory) { //MOV B,B	1		B <- B
+    },
+    0x41: func(core *Core8080, mem memory.Memory) { //MOV B,C	1		B <- C
         core.B = core.C
-        core.PC++
-    case 0x42://MOV B,D	1		B <- D
-        fmt.Printf("MOV B, D\n")
+    },
+    0x42: func(core *Core8080, mem memory.Memory) { //MOV B,D	1		B <- D
         core.B = core.D
-        core.PC++
-    case 0x43://MOV B,E	1		B <- E
-        fmt.Printf("MOV B, E\n")
+    },
+    0x43: func(core *Core8080, mem memory.Memory) { //MOV B,E	1		B <- E
         core.B = core.E
-        core.PC++
-    case 0x44://MOV B,H	1		B <- H
-        fmt.Printf("MOV B, H\n")
+    },
+    0x44: func(core *Core8080, mem memory.Memory) { //MOV B,H	1		B <- H
         core.B = core.H
-        core.PC++
-    case 0x45://MOV B,L	1		B <- L
-        fmt.Printf("MOV B, L\n")
+    },
+    0x45: func(core *Core8080, mem memory.Memory) { //MOV B,L	1		B <- L
         core.B = core.L
-        core.PC++
-    case 0x46://MOV B,M	1		B <- (HL)
-        fmt.Printf("MOV B, M\n")
-        addr := uint16(core.H) << 8
-        addr |= uint16(core.L)
-        core.B = mem.Read(addr)
-        core.PC++
-    case 0x47://MOV B,A	1		B <- A
-        fmt.Printf("MOV B, A\n")
+    },
+    0x46: func(core *Core8080, mem memory.Memory) { //MOV B,M	1		B <- (HL)
+        core.B = core.readM(mem)
+    },
+    0x47: func(core *Core8080, mem memory.Memory) { //MOV B,A	1		B <- A
         core.B = core.A
-        core.PC++
-    case 0x48://MOV C,B	1		C <- B
-        fmt.Printf("MOV C, B\n")
+    },
+    0x48: func(core *Core8080, mem memory.Memory) { //MOV C,B	1		C <- B
         core.C = core.B
-        core.PC++
-    case 0x49://MOV C,C	1		C <- C
-        fmt.Printf("MOV C, C\n")
-        core.PC++
-    case 0x4a://MOV C,D	1		C <- D
-        fmt.Printf("MOV C, D\n")
+    },
+    0x49: func(core *Core8080, mem memory.Memory) { //MOV C,C	1		C <- C
+    },
+    0x4a: func(core *Core8080, mem memory.Memory) { //MOV C,D	1		C <- D
         core.C = core.D
-        core.PC++
-    case 0x4b://MOV C,E	1		C <- E
-        fmt.Printf("MOV C, E\n")
+    },
+    0x4b: func(core *Core8080, mem memory.Memory) { //MOV C,E	1		C <- E
         core.C = core.E
-        core.PC++
-    case 0x4c://MOV C,H	1		C <- H
-        fmt.Printf("MOV C, H\n")
+    },
+    0x4c: func(core *Core8080, mem memory.Memory) { //MOV C,H	1		C <- H
         core.C = core.H
-        core.PC++
-    case 0x4d://MOV C,L	1		C <- L
-        fmt.Printf("MOV C, L\n")
+    },
+    0x4d: func(core *Core8080, mem memory.Memory) { //MOV C,L	1		C <- L
         core.C = core.L
-        core.PC++
-    case 0x4e://MOV C,M	1		C <- (HL)
-        fmt.Printf("MOV C, M\n")
-        addr := uint16(core.H) << 8
-        addr |= uint16(core.L)
-        core.C = mem.Read(addr)
-        core.PC++
-    case 0x4f://MOV C,A	1		C <- A
-        fmt.Printf("MOV C, A\n")
+    },
+    0x4e: func(core *Core8080, mem memory.Memory) { //MOV C,M	1		C <- (HL)
+        core.C = core.readM(mem)
+    },
+    0x4f: func(core *Core8080, mem memory.Memory) { //MOV C,A	1		C <- A
         core.C = core.A
-        core.PC++
-    case 0x50://MOV D,B	1		D <- B
-        fmt.Printf("MOV D, B\n")
+    },
+    0x50: func(core *Core8080, mem memory.Memory) { //MOV D,B	1		D <- B
         core.D = core.B
-        core.PC++
-    case 0x51://MOV D,C	1		D <- C
-        fmt.Printf("MOV D, C\n")
+    },
+    0x51: func(core *Core8080, mem memory.Memory) { //MOV D,C	1		D <- C
         core.D = core.C
-        core.PC++
-    case 0x52://MOV D,D	1		D <- D
-        fmt.Printf("MOV D, D\n")
-        core.D = core.D
-        core.PC++
-    case 0x53://MOV D,E	1		D <- E
-        fmt.Printf("MOV D, E\n")
+    },
+    0x52: func(core *Core8080, mem memory.Memory) { //MOV D,D	1		D <- D
+    },
+    0x53: func(core *Core8080, mem memory.Memory) { //MOV D,E	1		D <- E
         core.D = core.E
-        core.PC++
-    case 0x54://MOV D,H	1		D <- H
-        fmt.Printf("MOV D, H\n")
+    },
+    0x54: func(core *Core8080, mem memory.Memory) { //MOV D,H	1		D <- H
         core.D = core.H
-        core.PC++
-    case 0x55://MOV D,L	1		D <- L
-        fmt.Printf("MOV D, L\n")
+    },
+    0x55: func(core *Core8080, mem memory.Memory) { //MOV D,L	1		D <- L
         core.D = core.L
-        core.PC++
-    case 0x56://MOV D,M	1		D <- (HL)
-        fmt.Printf("MOV D, M\n")
-        addr := uint16(core.H) << 8
-        addr |= uint16(core.L)
-        core.D = mem.Read(addr)
-        core.PC++
-    case 0x57://MOV D,A	1		D <- A
-        fmt.Printf("MOV D, A\n")
+    },
+    0x56: func(core *Core8080, mem memory.Memory) { //MOV D,M	1		D <- (HL)
+        core.D = core.readM(mem)
+    },
+    0x57: func(core *Core8080, mem memory.Memory) { //MOV D,A	1		D <- A
         core.D = core.A
-        core.PC++
-    case 0x58://MOV E,B	1		E <- B
-        fmt.Printf("MOV E, B\n")
+    },
+    0x58: func(core *Core8080, mem memory.Memory) { //MOV E,B	1		E <- B
         core.E = core.B
-        core.PC++
-    case 0x59://MOV E,C	1		E <- C
-        fmt.Printf("MOV E, C\n")
+    },
+    0x59: func(core *Core8080, mem memory.Memory) { //MOV E,C	1		E <- C
         core.E = core.C
-        core.PC++
-    case 0x5a://MOV E,D	1		E <- D
-        fmt.Printf("MOV E, D\n")
+    },
+    0x5a: func(core *Core8080, mem memory.Memory) { //MOV E,D	1		E <- D
         core.E = core.D
-        core.PC++
-    case 0x5b://MOV E,E	1		E <- E
-        fmt.Printf("MOV E, E\n")
-        core.PC++
-    case 0x5c://MOV E,H	1		E <- H
-        fmt.Printf("MOV E, H\n")
+    },
+    0x5b: func(core *Core8080, mem memory.Memory) { //MOV E,E	1		E <- E
+    },
+    0x5c: func(core *Core8080, mem memory.Memory) { //MOV E,H	1		E <- H
         core.E = core.H
-        core.PC++
-    case 0x5d://MOV E,L	1		E <- L
-        fmt.Printf("MOV E, L\n")
+    },
+    0x5d: func(core *Core8080, mem memory.Memory) { //MOV E,L	1		E <- L
         core.E = core.L
-        core.PC++
-    case 0x5e://MOV E,M	1		E <- (HL)
-        fmt.Printf("MOV E, M\n")
-        addr := uint16(core.H) << 8
-        addr |= uint16(core.L)
-        core.E = mem.Read(addr)
-        core.PC++
-    case 0x5f://MOV E,A	1		E <- A
-        fmt.Printf("MOV E, A\n")
+    },
+    0x5e: func(core *Core8080, mem memory.Memory) { //MOV E,M	1		E <- (HL)
+        core.E = core.readM(mem)
+    },
+    0x5f: func(core *Core8080, mem memory.Memory) { //MOV E,A	1		E <- A
         core.E = core.A
-        core.PC++
-    case 0x60://MOV H,B	1		H <- B
-        fmt.Printf("MOV H, B\n")
-        core.H = core.B
-        core.PC++
-    case 0x61://MOV H,C	1		H <- C
-        fmt.Printf("MOV H, C\n")
+    },
+    0x60: func(core *Core8080, mem memory.Memory) { //MOV H,B	1		H <- B
         core.H = core.B
-        core.PC++
-    case 0x62://MOV H,D	1		H <- D
-        fmt.Printf("MOV H, D\n")
+    },
+    0x61: func(core *Core8080, mem memory.Memory) { //MOV H,C	1		H <- C
+        core.H = core.C
+    },
+    0x62: func(core *Core8080, mem memory.Memory) { //MOV H,D	1		H <- D
         core.H = core.D
-        core.PC++
-    case 0x63://MOV H,E	1		H <- E
-        fmt.Printf("MOV H, E\n")
+    },
+    0x63: func(core *Core8080, mem memory.Memory) { //MOV H,E	1		H <- E
         core.H = core.E
-        core.PC++
-    case 0x64://MOV H,H	1		H <- H
-        fmt.Printf("MOV H, H\n")
-        core.PC++
-    case 0x65://MOV H,L	1		H <- L
-        fmt.Printf("MOV H, L\n")
+    },
+    0x64: func(core *Core8080, mem memory.Memory) { //MOV H,H	1		H <- H
+    },
+    0x65: func(core *Core8080, mem memory.Memory) { //MOV H,L	1		H <- L
         core.H = core.L
-        core.PC++
-    case 0x66://MOV H,M	1		H <- (HL)
-        fmt.Printf("MOV H, M\n")
-        addr := uint16(core.H) << 8
-        addr |= uint16(core.L)
-        core.H = mem.Read(addr)
-        core.PC++
-    case 0x67://MOV H,A	1		H <- A
-        fmt.Printf("MOV H, A\n")
+    },
+    0x66: func(core *Core8080, mem memory.Memory) { //MOV H,M	1		H <- (HL)
+        core.H = core.readM(mem)
+    },
+    0x67: func(core *Core8080, mem memory.Memory) { //MOV H,A	1		H <- A
         core.H = core.A
-        core.PC++
-    case 0x68://MOV L,B	1		L <- B
-        fmt.Printf("MOV L, B\n")
+    },
+    0x68: func(core *Core8080, mem memory.Memory) { //MOV L,B	1		L <- B
         core.L = core.B
-        core.PC++
-    case 0x69://MOV L,C	1		L <- C
-        fmt.Printf("MOV L, C\n")
+    },
+    0x69: func(core *Core8080, mem memory.Memory) { //MOV L,C	1		L <- C
         core.L = core.C
-        core.PC++
-    case 0x6a://MOV L,D	1		L <- D
-        fmt.Printf("MOV L, D\n")
+    },
+    0x6a: func(core *Core8080, mem memory.Memory) { //MOV L,D	1		L <- D
         core.L = core.D
-        core.PC++
-    case 0x6b://MOV L,E	1		L <- E
-        fmt.Printf("MOV L, E\n")
+    },
+    0x6b: func(core *Core8080, mem memory.Memory) { //MOV L,E	1		L <- E
         core.L = core.E
-        core.PC++
-    case 0x6c://MOV L,H	1		L <- H
-        fmt.Printf("MOV L, H\n")
+    },
+    0x6c: func(core *Core8080, mem memory.Memory) { //MOV L,H	1		L <- H
         core.L = core.H
-        core.PC++
-    case 0x6d://MOV L,L	1		L <- L
-        fmt.Printf("MOV L, L\n")
-        core.PC++
-    case 0x6e://MOV L,M	1		L <- (HL)
-        fmt.Printf("MOV L, M\n")
-        addr := uint16(core.H) << 8
-        addr |= uint16(core.L)
-        core.L = mem.Read(addr)
-        core.PC++
-    case 0x6f://MOV L,A	1		L <- A
-        fmt.Printf("MOV L, A\n")
+    },
+    0x6d: func(core *Core8080, mem memory.Memory) { //MOV L,L	1		L <- L
+    },
+    0x6e: func(core *Core8080, mem memory.Memory) { //MOV L,M	1		L <- (HL)
+        core.L = core.readM(mem)
+    },
+    0x6f: func(core *Core8080, mem memory.Memory) { //MOV L,A	1		L <- A
         core.L = core.A
-        core.PC++
-    case 0x70://MOV M,B	1		(HL) <- B
-        fmt.Printf("MOV M, B\n")
-        addr := uint16(core.H) << 8
-        addr |= uint16(core.L)
-        mem.Write(addr, core.B)
-        core.PC++
-    case 0x71://MOV M,C	1		(HL) <- C
-        fmt.Printf("MOV M, C\n")
-        addr := uint16(core.H) << 8
-        addr |= uint16(core.L)
-        mem.Write(addr, core.C)
-        core.PC++
-    case 0x72://MOV M,D	1		(HL) <- D
-        fmt.Printf("MOV M, D\n")
-        addr := uint16(core.H) << 8
-        addr |= uint16(core.L)
-        mem.Write(addr, core.D)
-        core.PC++
-    case 0x73://MOV M,E	1		(HL) <- E
-        fmt.Printf("MOV M, E\n")
-        addr := uint16(core.H) << 8
-        addr |= uint16(core.L)
-        mem.Write(addr, core.E)
-        core.PC++
-    case 0x74://MOV M,H	1		(HL) <- H
-        fmt.Printf("MOV M, H\n")
-        addr := uint16(core.H) << 8
-        addr |= uint16(core.L)
-        mem.Write(addr, core.H)
-        core.PC++
-    case 0x75://MOV M,L	1		(HL) <- L
-        fmt.Printf("MOV M, L\n")
-        addr := uint16(core.H) << 8
-        addr |= uint16(core.L)
-        mem.Write(addr, core.L)
-        core.PC++
-    case 0x76://HLT	1		special
-        fmt.Printf("HLT\n")
-        core.PC++
-    case 0x77://MOV M,A	1		(HL) <- A
-        fmt.Printf("MOV M, A\n")
-        addr := uint16(core.H) << 8
-        addr |= uint16(core.L)
-        mem.Write(addr, core.A)
-        core.PC++
-    case 0x78://MOV A,B	1		A <- B
-        fmt.Printf("MOV A, B\n")
+    },
+    0x70: func(core *Core8080, mem memory.Memory) { //MOV M,B	1		(HL) <- B
+        core.writeM(mem, core.B)
+    },
+    0x71: func(core *Core8080, mem memory.Memory) { //MOV M,C	1		(HL) <- C
+        core.writeM(mem, core.C)
+    },
+    0x72: func(core *Core8080, mem memory.Memory) { //MOV M,D	1		(HL) <- D
+        core.writeM(mem, core.D)
+    },
+    0x73: func(core *Core8080, mem memory.Memory) { //MOV M,E	1		(HL) <- E
+        core.writeM(mem, core.E)
+    },
+    0x74: func(core *Core8080, mem memory.Memory) { //MOV M,H	1		(HL) <- H
+        core.writeM(mem, core.H)
+    },
+    0x75: func(core *Core8080, mem memory.Memory) { //MOV M,L	1		(HL) <- L
+        core.writeM(mem, core.L)
+    },
+    0x76: func(core *Core8080, mem memory.Memory) { //HLT	1		special
+        core.Halted = true
+    },
+    0x77: func(core *Core8080, mem memory.Memory) { //MOV M,A	1		(HL) <- A
+        core.writeM(mem, core.A)
+    },
+    0x78: func(core *Core8080, mem memory.Memory) { //MOV A,B	1		A <- B
         core.A = core.B
-        core.PC++
-    case 0x79://MOV A,C	1		A <- C
-        fmt.Printf("MOV A, C\n")
+    },
+    0x79: func(core *Core8080, mem memory.Memory) { //MOV A,C	1		A <- C
         core.A = core.C
-        core.PC++
-    case 0x7a://MOV A,D	1		A <- D
-        fmt.Printf("MOV A, D\n")
+    },
+    0x7a: func(core *Core8080, mem memory.Memory) { //MOV A,D	1		A <- D
         core.A = core.D
-        core.PC++
-    case 0x7b://MOV A,E	1		A <- E
-        fmt.Printf("MOV A, E\n")
+    },
+    0x7b: func(core *Core8080, mem memory.Memory) { //MOV A,E	1		A <- E
         core.A = core.E
-        core.PC++
-    case 0x7c://MOV A,H	1		A <- H
-        fmt.Printf("MOV A, H\n")
+    },
+    0x7c: func(core *Core8080, mem memory.Memory) { //MOV A,H	1		A <- H
+        core.A = core.H
+    },
+    0x7d: func(core *Core8080, mem memory.Memory) { //MOV A,L	1		A <- L
         core.A = core.L
-        core.PC++
-    case 0x7d://MOV A,L	1		A <- L
-        fmt.Printf("MOV A, L\n")
-        core.A = core.L
-        core.PC++
-    case 0x7e://MOV A,M	1		A <- (HL)
-        fmt.Printf("MOV A, M\n")
-        addr := uint16(core.H) << 8
-        addr |= uint16(core.L)
-        core.A = mem.Read(addr)
-        core.PC++
-    case 0x7f://MOV A,A	1		A <- A
-        fmt.Printf("MOV A, A\n")
-        core.PC++
-    case 0x80://ADD B	1	Z, S, P, CY, AC	A <- A + B
-        fmt.Printf("ADD B\n")
-        core.PC++
-    case 0x81://ADD C	1	Z, S, P, CY, AC	A <- A + C
-        fmt.Printf("ADD C\n")
-        core.PC++
-    case 0x82://ADD D	1	Z, S, P, CY, AC	A <- A + D
-        fmt.Printf("ADD D\n")
-        core.PC++
-    case 0x83://ADD E	1	Z, S, P, CY, AC	A <- A + E
-        fmt.Printf("ADD E\n")
-        core.PC++
-    case 0x84://ADD H	1	Z, S, P, CY, AC	A <- A + H
-        fmt.Printf("ADD H\n")
-        core.PC++
-    case 0x85://ADD L	1	Z, S, P, CY, AC	A <- A + L
-        fmt.Printf("ADD L\n")
-        core.PC++
-    case 0x86://ADD M	1	Z, S, P, CY, AC	A <- A + (HL)
-        fmt.Printf("ADD M\n")
-        core.PC++
-    case 0x87://ADD A	1	Z, S, P, CY, AC	A <- A + A
-        fmt.Printf("ADD A\n")
-        core.PC++
-    case 0x88://ADC B	1	Z, S, P, CY, AC	A <- A + B + CY
-        fmt.Printf("ADC B\n")
-        core.PC++
-    case 0x89://ADC C	1	Z, S, P, CY, AC	A <- A + C + CY
-        fmt.Printf("ADC C\n")
-        core.PC++
-    case 0x8a://ADC D	1	Z, S, P, CY, AC	A <- A + D + CY
-        fmt.Printf("ADC D\n")
-        core.PC++
-    case 0x8b://ADC E	1	Z, S, P, CY, AC	A <- A + E + CY
-        fmt.Printf("ADC E\n")
-        core.PC++
-    case 0x8c://ADC H	1	Z, S, P, CY, AC	A <- A + H + CY
-        fmt.Printf("ADC H\n")
-        core.PC++
-    case 0x8d://ADC L	1	Z, S, P, CY, AC	A <- A + L + CY
-        fmt.Printf("ADC L\n")
-        core.PC++
-    case 0x8e://ADC M	1	Z, S, P, CY, AC	A <- A + (HL) + CY
-        fmt.Printf("ADC M\n")
-        core.PC++
-    case 0x8f://ADC A	1	Z, S, P, CY, AC	A <- A + A + CY
-        fmt.Printf("ADC A\n")
-        core.PC++
-    case 0x90://SUB B	1	Z, S, P, CY, AC	A <- A - B
-        fmt.Printf("SUB B\n")
-        core.PC++
-    case 0x91://SUB C	1	Z, S, P, CY, AC	A <- A - C
-        fmt.Printf("SUB C\n")
-        core.PC++
-    case 0x92://SUB D	1	Z, S, P, CY, AC	A <- A + D
-        fmt.Printf("SUB D\n")
-        core.PC++
-    case 0x93://SUB E	1	Z, S, P, CY, AC	A <- A - E
-        fmt.Printf("SUB E\n")
-        core.PC++
-    case 0x94://SUB H	1	Z, S, P, CY, AC	A <- A + H
-        fmt.Printf("SUB H\n")
-        core.PC++
-    case 0x95://SUB L	1	Z, S, P, CY, AC	A <- A - L
-        fmt.Printf("SUB L\n")
-        core.PC++
-    case 0x96://SUB M	1	Z, S, P, CY, AC	A <- A + (HL)
-        fmt.Printf("SUB M\n")
-        core.PC++
-    case 0x97://SUB A	1	Z, S, P, CY, AC	A <- A - A
-        fmt.Printf("SUB A\n")
-        core.PC++
-    case 0x98://SBB B	1	Z, S, P, CY, AC	A <- A - B - CY
-        fmt.Printf("SBB B\n")
-        core.PC++
-    case 0x99://SBB C	1	Z, S, P, CY, AC	A <- A - C - CY
-        fmt.Printf("SBB C\n")
-        core.PC++
-    case 0x9a://SBB D	1	Z, S, P, CY, AC	A <- A - D - CY
-        fmt.Printf("SBB D\n")
-        core.PC++
-    case 0x9b://SBB E	1	Z, S, P, CY, AC	A <- A - E - CY
-        fmt.Printf("SBB E\n")
-        core.PC++
-    case 0x9c://SBB H	1	Z, S, P, CY, AC	A <- A - H - CY
-        fmt.Printf("SBB H\n")
-        core.PC++
-    case 0x9d://SBB L	1	Z, S, P, CY, AC	A <- A - L - CY
-        fmt.Printf("SBB L\n")
-        core.PC++
-    case 0x9e://SBB M	1	Z, S, P, CY, AC	A <- A - (HL) - CY
-        fmt.Printf("SBB M\n")
-        core.PC++
-    case 0x9f://SBB A	1	Z, S, P, CY, AC	A <- A - A - CY
-        fmt.Printf("SBB A\n")
-        core.PC++
-    case 0xa0://ANA B	1	Z, S, P, CY, AC	A <- A & B
-        fmt.Printf("ANA B\n")
-        core.PC++
-    case 0xa1://ANA C	1	Z, S, P, CY, AC	A <- A & C
-        fmt.Printf("ANA C\n")
-        core.PC++
-    case 0xa2://ANA D	1	Z, S, P, CY, AC	A <- A & D
-        fmt.Printf("ANA D\n")
-        core.PC++
-    case 0xa3://ANA E	1	Z, S, P, CY, AC	A <- A & E
-        fmt.Printf("ANA E\n")
-        core.PC++
-    case 0xa4://ANA H	1	Z, S, P, CY, AC	A <- A & H
-        fmt.Printf("ANA\n")
-        core.PC++
-    case 0xa5://ANA L	1	Z, S, P, CY, AC	A <- A & L
-        fmt.Printf("ANA L\n")
-        core.PC++
-    case 0xa6://ANA M	1	Z, S, P, CY, AC	A <- A & (HL)
-        fmt.Printf("ANA M\n")
-        core.PC++
-    case 0xa7://ANA A	1	Z, S, P, CY, AC	A <- A & A
-        fmt.Printf("ANA A\n")
-        core.PC++
-    case 0xa8://XRA B	1	Z, S, P, CY, AC	A <- A ^ B
-        fmt.Printf("XRA B\n")
-        core.PC++
-    case 0xa9://XRA C	1	Z, S, P, CY, AC	A <- A ^ C
-        fmt.Printf("XRA C\n")
-        core.PC++
-    case 0xaa://XRA D	1	Z, S, P, CY, AC	A <- A ^ D
-        fmt.Printf("XRA D\n")
-        core.PC++
-    case 0xab://XRA E	1	Z, S, P, CY, AC	A <- A ^ E
-        fmt.Printf("XRA E\n")
-        core.PC++
-    case 0xac://XRA H	1	Z, S, P, CY, AC	A <- A ^ H
-        fmt.Printf("XRA H\n")
-        core.PC++
-    case 0xad://XRA L	1	Z, S, P, CY, AC	A <- A ^ L
-        fmt.Printf("XRA L\n")
-        core.PC++
-    case 0xae://XRA M	1	Z, S, P, CY, AC	A <- A ^ (HL)
-        fmt.Printf("XRA M\n")
-        core.PC++
-    case 0xaf://XRA A	1	Z, S, P, CY, AC	A <- A ^ A
-        fmt.Printf("XRA A\n")
-        core.PC++
-    case 0xb0://ORA B	1	Z, S, P, CY, AC	A <- A | B
-        fmt.Printf("ORA B\n")
-        core.PC++
-    case 0xb1://ORA C	1	Z, S, P, CY, AC	A <- A | C
-        fmt.Printf("ORA C\n")
-        core.PC++
-    case 0xb2://ORA D	1	Z, S, P, CY, AC	A <- A | D
-        fmt.Printf("ORA D\n")
-        core.PC++
-    case 0xb3://ORA E	1	Z, S, P, CY, AC	A <- A | E
-        fmt.Printf("ORA E\n")
-        core.PC++
-    case 0xb4://ORA H	1	Z, S, P, CY, AC	A <- A | H
-        fmt.Printf("ORA H\n")
-        core.PC++
-    case 0xb5://ORA L	1	Z, S, P, CY, AC	A <- A | L
-        fmt.Printf("ORA L\n")
-        core.PC++
-    case 0xb6://ORA M	1	Z, S, P, CY, AC	A <- A | (HL)
-        fmt.Printf("ORA M\n")
-        core.PC++
-    case 0xb7://ORA A	1	Z, S, P, CY, AC	A <- A | A
-        fmt.Printf("ORA A\n")
-        core.PC++
-    case 0xb8://CMP B	1	Z, S, P, CY, AC	A - B
-        fmt.Printf("CMP B\n")
-        core.PC++
-    case 0xb9://CMP C	1	Z, S, P, CY, AC	A - C
-        fmt.Printf("CMP C\n")
-        core.PC++
-    case 0xba://CMP D	1	Z, S, P, CY, AC	A - D
-        fmt.Printf("CMP D\n")
-        core.PC++
-    case 0xbb://CMP E	1	Z, S, P, CY, AC	A - E
-        fmt.Printf("CMP E\n")
-        core.PC++
-    case 0xbc://CMP H	1	Z, S, P, CY, AC	A - H
-        fmt.Printf("CMP H\n")
-        core.PC++
-    case 0xbd://CMP L	1	Z, S, P, CY, AC	A - L
-        fmt.Printf("CMP L\n")
-        core.PC++
-    case 0xbe://CMP M	1	Z, S, P, CY, AC	A - (HL)
-        fmt.Printf("CMP M\n")
-        core.PC++
-    case 0xbf://CMP A	1	Z, S, P, CY, AC	A - A
-        fmt.Printf("CMP A\n")
-        core.PC++
-    case 0xc0://RNZ	1		if NZ, RET
-        fmt.Printf("RNZ\n")
-        core.PC++
-    case 0xc1://POP B	1		C <- (sp); B <- (sp+1); sp <- sp+2
-        fmt.Printf("POP B\n")
-        core.PC++
-    case 0xc2://JNZ adr	3		if NZ, PC <- adr
-        fmt.Printf("JNZ adr\n")
-        core.PC += 3
-    case 0xc3://JMP adr	3		PC <= adr
-        fmt.Printf("JMP adr\n")
-        addr := uint16(opcode[2]) << 8
-        addr |= uint16(opcode[1])
-        core.PC += addr
-    case 0xc4://CNZ adr	3		if NZ, CALL adr
-        fmt.Printf("CNZ adr\n")
-        core.PC += 3
-    case 0xc5://PUSH B	1		(sp-2)<-C; (sp-1)<-B; sp <- sp - 2
-        fmt.Printf("PUSH B\n")
-        core.PC++
-    case 0xc6://ADI D8	2	Z, S, P, CY, AC	A <- A + byte
-        fmt.Printf("ADI D8\n")
-        core.PC += 2
-    case 0xc7://RST 0	1		CALL $0
-        fmt.Printf("RST\n")
-        core.PC++
-    case 0xc8://RZ	1		if Z, RET
-        fmt.Printf("RZ\n")
-        core.PC++
-    case 0xc9://RET	1		PC.lo <- (sp); PC.hi<-(sp+1); SP <- SP+2
-        fmt.Printf("RET\n")
-        core.PC++
-    case 0xca://JZ 3		if Z, PC <- adr
-        fmt.Printf("JZ\n")
-        core.PC += 3
-    case 0xcb://-			
-        fmt.Printf("Invalid Instruction\n")
-        core.PC++
-    case 0xcc://CZ adr	3		if Z, CALL adr
-        fmt.Printf("CZ adr\n")
-        core.PC += 3
-    case 0xcd://CALL adr	3		(SP-1)<-PC.hi;(SP-2)<-PC.lo;SP<-SP-2;PC=adr
-        fmt.Printf("CALL adr\n")
-        core.PC += 3
-    case 0xce://ACI D8	2	Z, S, P, CY, AC	A <- A + data + CY
-        fmt.Printf("ACI D8\n")
-        core.PC += 2
-    case 0xcf://RST 1	1		CALL $8
-        fmt.Printf("RST\n")
-        core.PC++
-    case 0xd0://RNC	1		if NCY, RET
-        fmt.Printf("RNC\n")
-        core.PC++
-    case 0xd1://POP D	1		E <- (sp); D <- (sp+1); sp <- sp+2
-        fmt.Printf("POP D\n")
-        core.PC++
-    case 0xd2://JNC adr	3		if NCY, PC<-adr
-        fmt.Printf("JNC\n")
-        core.PC += 3
-    case 0xd3://OUT D8	2		special
-        fmt.Printf("OUT\n")
-        core.PC +=2
-    case 0xd4://CNC adr	3		if NCY, CALL adr
-        fmt.Printf("CNC adr\n")
-        core.PC += 3
-    case 0xd5://PUSH D	1		(sp-2)<-E; (sp-1)<-D; sp <- sp - 2
-        fmt.Printf("PUSH D\n")
-        core.PC++
-    case 0xd6://SUI D8	2	Z, S, P, CY, AC	A <- A - data
-        fmt.Printf("SUI D8\n")
-        core.PC += 2
-    case 0xd7://RST 2	1		CALL $10
-        fmt.Printf("RST 2\n")
-        core.PC++
-    case 0xd8://RC	1		if CY, RET
-        fmt.Printf("RC\n")
-        core.PC++
-    case 0xd9://-			
-        fmt.Printf("Invalid Instruction\n")
-        core.PC++
-    case 0xda://JC adr	3		if CY, PC<-adr
-        fmt.Printf("JC adr\n")
-        core.PC += 3
-    case 0xdb://IN D8	2		special
-        fmt.Printf("IN D8\n")
-        core.PC += 2
-    case 0xdc://CC adr	3		if CY, CALL adr
-        fmt.Printf("CC adr\n")
-        core.PC += 3
-    case 0xdd://-			
-        fmt.Printf("Invalid Instruction\n")
-        core.PC++
-    case 0xde://SBI D8	2	Z, S, P, CY, AC	A <- A - data - CY
-        fmt.Printf("SBI D8\n")
-        core.PC += 2
-    case 0xdf://RST 3	1		CALL $18
-        fmt.Printf("RST\n")
-        core.PC++
-    case 0xe0://RPO	1		if PO, RET
-        fmt.Printf("RPO\n")
-        core.PC++
-    case 0xe1://POP H	1		L <- (sp); H <- (sp+1); sp <- sp+2
-        fmt.Printf("POP\n")
-        core.PC++
-    case 0xe2://JPO adr	3		if PO, PC <- adr
-        fmt.Printf("JPO adr\n")
-        core.PC += 3
-    case 0xe3://XTHL	1		L <-> (SP); H <-> (SP+1)
-        fmt.Printf("XTHL\n")
-        core.PC++
-    case 0xe4://CPO adr	3		if PO, CALL adr
-        fmt.Printf("CPO adr\n")
-        core.PC += 3
-    case 0xe5://PUSH H	1		(sp-2)<-L; (sp-1)<-H; sp <- sp - 2
-        fmt.Printf("PUSH H\n")
-        core.PC++
-    case 0xe6://ANI D8	2	Z, S, P, CY, AC	A <- A & data
-        fmt.Printf("ANI D8\n")
-        core.PC += 2
-    case 0xe7://RST 4	1		CALL $20
-        fmt.Printf("RST 4\n")
-        core.PC++
-    case 0xe8://RPE	1		if PE, RET
-        fmt.Printf("RPE 1\n")
-        core.PC++
-    case 0xe9://PCHL	1		PC.hi <- H; PC.lo <- L
-        fmt.Printf("PCHL\n")
-        core.PC++
-    case 0xea://JPE adr	3		if PE, PC <- adr
-        fmt.Printf("JPE adr\n")
-        core.PC += 3
-    case 0xeb://XCHG	1		H <-> D; L <-> E
-        fmt.Printf("XCHG\n")
-        core.PC++
-    case 0xec://CPE adr	3		if PE, CALL adr
-        fmt.Printf("CPE adr\n")
-        core.PC += 3
-    case 0xed://-			
-        fmt.Printf("Invalid Instruction\n")
-        core.PC++
-    case 0xee://XRI D8	2	Z, S, P, CY, AC	A <- A ^ data
-        fmt.Printf("XRI D8\n")
-        core.PC += 2
-    case 0xef://RST 5	1		CALL $28
-        fmt.Printf("RST 5\n")
-        core.PC++
-    case 0xf0://RP	1		if P, RET
-        fmt.Printf("RP 1\n")
-        core.PC++
-    case 0xf1://POP PSW	1		flags <- (sp); A <- (sp+1); sp <- sp+2
-        fmt.Printf("POP PSW 1\n")
-        core.PC++
-    case 0xf2://JP adr	3		if P=1 PC <- adr
-        fmt.Printf("JP adr\n")
-        core.PC += 3
-    case 0xf3://DI	1		special
-        fmt.Printf("DI\n")
-        core.PC++
-    case 0xf4://CP adr	3		if P, PC <- adr
-        fmt.Printf("CP adr\n")
-        core.PC += 3
-    case 0xf5://PUSH PSW	1		(sp-2)<-flags; (sp-1)<-A; sp <- sp - 2
-        fmt.Printf("PUSH PSW\n")
-        core.PC++
-    case 0xf6://ORI D8	2	Z, S, P, CY, AC	A <- A | data
-        fmt.Printf("ORI D8\n")
-        core.PC += 2
-    case 0xf7://RST 6	1		CALL $30
-        fmt.Printf("RST 6\n")
-        core.PC++
-    case 0xf8://RM	1		if M, RET
-        fmt.Printf("RM\n")
-        core.PC++
-    case 0xf9://SPHL	1		SP=HL
-        fmt.Printf("SPHL\n")
-        core.PC++
-    case 0xfa://JM adr	3		if M, PC <- adr
-        fmt.Printf("JM adr\n")
-        core.PC += 3
-    case 0xfb:	//EI	1		special
-        fmt.Printf("EI\n")
-        core.PC++
-    case 0xfc:	//CM adr	3		if M, CALL adr
-        fmt.Printf("CM adr\n")
-        core.PC += 3
-    case 0xfd:	//-			
-        fmt.Printf("Invalid Instruction\n")
-        core.PC++
-    case 0xfe:	//CPI D8	2	Z, S, P, CY, AC	A - data
-        fmt.Printf("CPI D8\n")
-        core.PC += 2
-    case 0xff:	//RST 7	1		CALL $38
-        fmt.Printf("RST 7\n")
-        core.PC++
-    }
+    },
+    0x7e: func(core *Core8080, mem memory.Memory) { //MOV A,M	1		A <- (HL)
+        core.A = core.readM(mem)
+    },
+    0x7f: func(core *Core8080, mem memory.Memory) { //MOV A,A	1		A <- A
+    },
+    0x80: func(core *Core8080, mem memory.Memory) { //ADD B	1	Z, S, P, CY, AC	A <- A + B
+        core.add(core.B, false)
+    },
+    0x81: func(core *Core8080, mem memory.Memory) { //ADD C	1	Z, S, P, CY, AC	A <- A + C
+        core.add(core.C, false)
+    },
+    0x82: func(core *Core8080, mem memory.Memory) { //ADD D	1	Z, S, P, CY, AC	A <- A + D
+        core.add(core.D, false)
+    },
+    0x83: func(core *Core8080, mem memory.Memory) { //ADD E	1	Z, S, P, CY, AC	A <- A + E
+        core.add(core.E, false)
+    },
+    0x84: func(core *Core8080, mem memory.Memory) { //ADD H	1	Z, S, P, CY, AC	A <- A + H
+        core.add(core.H, false)
+    },
+    0x85: func(core *Core8080, mem memory.Memory) { //ADD L	1	Z, S, P, CY, AC	A <- A + L
+        core.add(core.L, false)
+    },
+    0x86: func(core *Core8080, mem memory.Memory) { //ADD M	1	Z, S, P, CY, AC	A <- A + (HL)
+        core.add(core.readM(mem), false)
+    },
+    0x87: func(core *Core8080, mem memory.Memory) { //ADD A	1	Z, S, P, CY, AC	A <- A + A
+        core.add(core.A, false)
+    },
+    0x88: func(core *Core8080, mem memory.Memory) { //ADC B	1	Z, S, P, CY, AC	A <- A + B + CY
+        core.add(core.B, core.flag(FlagCY))
+    },
+    0x89: func(core *Core8080, mem memory.Memory) { //ADC C	1	Z, S, P, CY, AC	A <- A + C + CY
+        core.add(core.C, core.flag(FlagCY))
+    },
+    0x8a: func(core *Core8080, mem memory.Memory) { //ADC D	1	Z, S, P, CY, AC	A <- A + D + CY
+        core.add(core.D, core.flag(FlagCY))
+    },
+    0x8b: func(core *Core8080, mem memory.Memory) { //ADC E	1	Z, S, P, CY, AC	A <- A + E + CY
+        core.add(core.E, core.flag(FlagCY))
+    },
+    0x8c: func(core *Core8080, mem memory.Memory) { //ADC H	1	Z, S, P, CY, AC	A <- A + H + CY
+        core.add(core.H, core.flag(FlagCY))
+    },
+    0x8d: func(core *Core8080, mem memory.Memory) { //ADC L	1	Z, S, P, CY, AC	A <- A + L + CY
+        core.add(core.L, core.flag(FlagCY))
+    },
+    0x8e: func(core *Core8080, mem memory.Memory) { //ADC M	1	Z, S, P, CY, AC	A <- A + (HL) + CY
+        core.add(core.readM(mem), core.flag(FlagCY))
+    },
+    0x8f: func(core *Core8080, mem memory.Memory) { //ADC A	1	Z, S, P, CY, AC	A <- A + A + CY
+        core.add(core.A, core.flag(FlagCY))
+    },
+    0x90: func(core *Core8080, mem memory.Memory) { //SUB B	1	Z, S, P, CY, AC	A <- A - B
+        core.sub(core.B, false)
+    },
+    0x91: func(core *Core8080, mem memory.Memory) { //SUB C	1	Z, S, P, CY, AC	A <- A - C
+        core.sub(core.C, false)
+    },
+    0x92: func(core *Core8080, mem memory.Memory) { //SUB D	1	Z, S, P, CY, AC	A <- A - D
+        core.sub(core.D, false)
+    },
+    0x93: func(core *Core8080, mem memory.Memory) { //SUB E	1	Z, S, P, CY, AC	A <- A - E
+        core.sub(core.E, false)
+    },
+    0x94: func(core *Core8080, mem memory.Memory) { //SUB H	1	Z, S, P, CY, AC	A <- A - H
+        core.sub(core.H, false)
+    },
+    0x95: func(core *Core8080, mem memory.Memory) { //SUB L	1	Z, S, P, CY, AC	A <- A - L
+        core.sub(core.L, false)
+    },
+    0x96: func(core *Core8080, mem memory.Memory) { //SUB M	1	Z, S, P, CY, AC	A <- A - (HL)
+        core.sub(core.readM(mem), false)
+    },
+    0x97: func(core *Core8080, mem memory.Memory) { //SUB A	1	Z, S, P, CY, AC	A <- A - A
+        core.sub(core.A, false)
+    },
+    0x98: func(core *Core8080, mem memory.Memory) { //SBB B	1	Z, S, P, CY, AC	A <- A - B - CY
+        core.sub(core.B, core.flag(FlagCY))
+    },
+    0x99: func(core *Core8080, mem memory.Memory) { //SBB C	1	Z, S, P, CY, AC	A <- A - C - CY
+        core.sub(core.C, core.flag(FlagCY))
+    },
+    0x9a: func(core *Core8080, mem memory.Memory) { //SBB D	1	Z, S, P, CY, AC	A <- A - D - CY
+        core.sub(core.D, core.flag(FlagCY))
+    },
+    0x9b: func(core *Core8080, mem memory.Memory) { //SBB E	1	Z, S, P, CY, AC	A <- A - E - CY
+        core.sub(core.E, core.flag(FlagCY))
+    },
+    0x9c: func(core *Core8080, mem memory.Memory) { //SBB H	1	Z, S, P, CY, AC	A <- A - H - CY
+        core.sub(core.H, core.flag(FlagCY))
+    },
+    0x9d: func(core *Core8080, mem memory.Memory) { //SBB L	1	Z, S, P, CY, AC	A <- A - L - CY
+        core.sub(core.L, core.flag(FlagCY))
+    },
+    0x9e: func(core *Core8080, mem memory.Memory) { //SBB M	1	Z, S, P, CY, AC	A <- A - (HL) - CY
+        core.sub(core.readM(mem), core.flag(FlagCY))
+    },
+    0x9f: func(core *Core8080, mem memory.Memory) { //SBB A	1	Z, S, P, CY, AC	A <- A - A - CY
+        core.sub(core.A, core.flag(FlagCY))
+    },
+    0xa0: func(core *Core8080, mem memory.Memory) { //ANA B	1	Z, S, P, CY, AC	A <- A & B
+        core.ana(core.B)
+    },
+    0xa1: func(core *Core8080, mem memory.Memory) { //ANA C	1	Z, S, P, CY, AC	A <- A & C
+        core.ana(core.C)
+    },
+    0xa2: func(core *Core8080, mem memory.Memory) { //ANA D	1	Z, S, P, CY, AC	A <- A & D
+        core.ana(core.D)
+    },
+    0xa3: func(core *Core8080, mem memory.Memory) { //ANA E	1	Z, S, P, CY, AC	A <- A & E
+        core.ana(core.E)
+    },
+    0xa4: func(core *Core8080, mem memory.Memory) { //ANA H	1	Z, S, P, CY, AC	A <- A & H
+        core.ana(core.H)
+    },
+    0xa5: func(core *Core8080, mem memory.Memory) { //ANA L	1	Z, S, P, CY, AC	A <- A & L
+        core.ana(core.L)
+    },
+    0xa6: func(core *Core8080, mem memory.Memory) { //ANA M	1	Z, S, P, CY, AC	A <- A & (HL)
+        core.ana(core.readM(mem))
+    },
+    0xa7: func(core *Core8080, mem memory.Memory) { //ANA A	1	Z, S, P, CY, AC	A <- A & A
+        core.ana(core.A)
+    },
+    0xa8: func(core *Core8080, mem memory.Memory) { //XRA B	1	Z, S, P, CY, AC	A <- A ^ B
+        core.xra(core.B)
+    },
+    0xa9: func(core *Core8080, mem memory.Memory) { //XRA C	1	Z, S, P, CY, AC	A <- A ^ C
+        core.xra(core.C)
+    },
+    0xaa: func(core *Core8080, mem memory.Memory) { //XRA D	1	Z, S, P, CY, AC	A <- A ^ D
+        core.xra(core.D)
+    },
+    0xab: func(core *Core8080, mem memory.Memory) { //XRA E	1	Z, S, P, CY, AC	A <- A ^ E
+        core.xra(core.E)
+    },
+    0xac: func(core *Core8080, mem memory.Memory) { //XRA H	1	Z, S, P, CY, AC	A <- A ^ H
+        core.xra(core.H)
+    },
+    0xad: func(core *Core8080, mem memory.Memory) { //XRA L	1	Z, S, P, CY, AC	A <- A ^ L
+        core.xra(core.L)
+    },
+    0xae: func(core *Core8080, mem memory.Memory) { //XRA M	1	Z, S, P, CY, AC	A <- A ^ (HL)
+        core.xra(core.readM(mem))
+    },
+    0xaf: func(core *Core8080, mem memory.Memory) { //XRA A	1	Z, S, P, CY, AC	A <- A ^ A
+        core.xra(core.A)
+    },
+    0xb0: func(core *Core8080, mem memory.Memory) { //ORA B	1	Z, S, P, CY, AC	A <- A | B
+        core.ora(core.B)
+    },
+    0xb1: func(core *Core8080, mem memory.Memory) { //ORA C	1	Z, S, P, CY, AC	A <- A | C
+        core.ora(core.C)
+    },
+    0xb2: func(core *Core8080, mem memory.Memory) { //ORA D	1	Z, S, P, CY, AC	A <- A | D
+        core.ora(core.D)
+    },
+    0xb3: func(core *Core8080, mem memory.Memory) { //ORA E	1	Z, S, P, CY, AC	A <- A | E
+        core.ora(core.E)
+    },
+    0xb4: func(core *Core8080, mem memory.Memory) { //ORA H	1	Z, S, P, CY, AC	A <- A | H
+        core.ora(core.H)
+    },
+    0xb5: func(core *Core8080, mem memory.Memory) { //ORA L	1	Z, S, P, CY, AC	A <- A | L
+        core.ora(core.L)
+    },
+    0xb6: func(core *Core8080, mem memory.Memory) { //ORA M	1	Z, S, P, CY, AC	A <- A | (HL)
+        core.ora(core.readM(mem))
+    },
+    0xb7: func(core *Core8080, mem memory.Memory) { //ORA A	1	Z, S, P, CY, AC	A <- A | A
+        core.ora(core.A)
+    },
+    0xb8: func(core *Core8080, mem memory.Memory) { //CMP B	1	Z, S, P, CY, AC	A - B
+        core.cmp(core.B)
+    },
+    0xb9: func(core *Core8080, mem memory.Memory) { //CMP C	1	Z, S, P, CY, AC	A - C
+        core.cmp(core.C)
+    },
+    0xba: func(core *Core8080, mem memory.Memory) { //CMP D	1	Z, S, P, CY, AC	A - D
+        core.cmp(core.D)
+    },
+    0xbb: func(core *Core8080, mem memory.Memory) { //CMP E	1	Z, S, P, CY, AC	A - E
+        core.cmp(core.E)
+    },
+    0xbc: func(core *Core8080, mem memory.Memory) { //CMP H	1	Z, S, P, CY, AC	A - H
+        core.cmp(core.H)
+    },
+    0xbd: func(core *Core8080, mem memory.Memory) { //CMP L	1	Z, S, P, CY, AC	A - L
+        core.cmp(core.L)
+    },
+    0xbe: func(core *Core8080, mem memory.Memory) { //CMP M	1	Z, S, P, CY, AC	A - (HL)
+        core.cmp(core.readM(mem))
+    },
+    0xbf: func(core *Core8080, mem memory.Memory) { //CMP A	1	Z, S, P, CY, AC	A - A
+        core.cmp(core.A)
+    },
+    0xc0: func(core *Core8080, mem memory.Memory) { //RNZ	1		if NZ, RET
+        if !core.flag(FlagZ) {
+            core.cycles += 6
+            core.ret(mem)
+        }
+    },
+    0xc1: func(core *Core8080, mem memory.Memory) { //POP B	1		C <- (sp); B <- (sp+1); sp <- sp+2
+        core.SetBC(core.pop(mem))
+    },
+    0xc2: func(core *Core8080, mem memory.Memory) { //JNZ adr	3		if NZ, PC <- adr
+        if !core.flag(FlagZ) {
+            core.PC = core.imm16()
+        }
+    },
+    0xc3: func(core *Core8080, mem memory.Memory) { //JMP adr	3		PC <= adr
+        core.PC = core.imm16()
+    },
+    0xc4: func(core *Core8080, mem memory.Memory) { //CNZ adr	3		if NZ, CALL adr
+        if !core.flag(FlagZ) {
+            core.cycles += 6
+            core.call(mem, core.imm16())
+        }
+    },
+    0xc5: func(core *Core8080, mem memory.Memory) { //PUSH B	1		(sp-2)<-C; (sp-1)<-B; sp <- sp - 2
+        core.push(mem, core.BC())
+    },
+    0xc6: func(core *Core8080, mem memory.Memory) { //ADI D8	2	Z, S, P, CY, AC	A <- A + byte
+        core.add(core.op[1], false)
+    },
+    0xc7: func(core *Core8080, mem memory.Memory) { //RST 0	1		CALL $0
+        core.call(mem, 0x00)
+    },
+    0xc8: func(core *Core8080, mem memory.Memory) { //RZ	1		if Z, RET
+        if core.flag(FlagZ) {
+            core.cycles += 6
+            core.ret(mem)
+        }
+    },
+    0xc9: func(core *Core8080, mem memory.Memory) { //RET	1		PC.lo <- (sp); PC.hi<-(sp+1); SP <- SP+2
+        core.ret(mem)
+    },
+    0xca: func(core *Core8080, mem memory.Memory) { //JZ adr	3		if Z, PC <- adr
+        if core.flag(FlagZ) {
+            core.PC = core.imm16()
+        }
+    },
+    0xcb: func(core *Core8080, mem memory.Memory) { //-
+        core.PC = core.imm16()
+    },
+    0xcc: func(core *Core8080, mem memory.Memory) { //CZ adr	3		if Z, CALL adr
+        if core.flag(FlagZ) {
+            core.cycles += 6
+            core.call(mem, core.imm16())
+        }
+    },
+    0xcd: func(core *Core8080, mem memory.Memory) { //CALL adr	3		(SP-1)<-PC.hi;(SP-2)<-PC.lo;SP<-SP-2;PC=adr
+        core.call(mem, core.imm16())
+    },
+    0xce: func(core *Core8080, mem memory.Memory) { //ACI D8	2	Z, S, P, CY, AC	A <- A + data + CY
+        core.add(core.op[1], core.flag(FlagCY))
+    },
+    0xcf: func(core *Core8080, mem memory.Memory) { //RST 1	1		CALL $8
+        core.call(mem, 0x08)
+    },
+    0xd0: func(core *Core8080, mem memory.Memory) { //RNC	1		if NCY, RET
+        if !core.flag(FlagCY) {
+            core.cycles += 6
+            core.ret(mem)
+        }
+    },
+    0xd1: func(core *Core8080, mem memory.Memory) { //POP D	1		E <- (sp); D <- (sp+1); sp <- sp+2
+        core.SetDE(core.pop(mem))
+    },
+    0xd2: func(core *Core8080, mem memory.Memory) { //JNC adr	3		if NCY, PC<-adr
+        if !core.flag(FlagCY) {
+            core.PC = core.imm16()
+        }
+    },
+    0xd3: func(core *Core8080, mem memory.Memory) { //OUT D8	2		special
+        if core.IO != nil {
+            core.IO.Out(core.op[1], core.A)
+        }
+    },
+    0xd4: func(core *Core8080, mem memory.Memory) { //CNC adr	3		if NCY, CALL adr
+        if !core.flag(FlagCY) {
+            core.cycles += 6
+            core.call(mem, core.imm16())
+        }
+    },
+    0xd5: func(core *Core8080, mem memory.Memory) { //PUSH D	1		(sp-2)<-E; (sp-1)<-D; sp <- sp - 2
+        core.push(mem, core.DE())
+    },
+    0xd6: func(core *Core8080, mem memory.Memory) { //SUI D8	2	Z, S, P, CY, AC	A <- A - data
+        core.sub(core.op[1], false)
+    },
+    0xd7: func(core *Core8080, mem memory.Memory) { //RST 2	1		CALL $10
+        core.call(mem, 0x10)
+    },
+    0xd8: func(core *Core8080, mem memory.Memory) { //RC	1		if CY, RET
+        if core.flag(FlagCY) {
+            core.cycles += 6
+            core.ret(mem)
+        }
+    },
+    0xd9: func(core *Core8080, mem memory.Memory) { //-
+        core.ret(mem)
+    },
+    0xda: func(core *Core8080, mem memory.Memory) { //JC adr	3		if CY, PC<-adr
+        if core.flag(FlagCY) {
+            core.PC = core.imm16()
+        }
+    },
+    0xdb: func(core *Core8080, mem memory.Memory) { //IN D8	2		special
+        if core.IO != nil {
+            core.A = core.IO.In(core.op[1])
+        }
+    },
+    0xdc: func(core *Core8080, mem memory.Memory) { //CC adr	3		if CY, CALL adr
+        if core.flag(FlagCY) {
+            core.cycles += 6
+            core.call(mem, core.imm16())
+        }
+    },
+    0xdd: func(core *Core8080, mem memory.Memory) { //-
+        core.call(mem, core.imm16())
+    },
+    0xde: func(core *Core8080, mem memory.Memory) { //SBI D8	2	Z, S, P, CY, AC	A <- A - data - CY
+        core.sub(core.op[1], core.flag(FlagCY))
+    },
+    0xdf: func(core *Core8080, mem memory.Memory) { //RST 3	1		CALL $18
+        core.call(mem, 0x18)
+    },
+    0xe0: func(core *Core8080, mem memory.Memory) { //RPO	1		if PO, RET
+        if !core.flag(FlagP) {
+            core.cycles += 6
+            core.ret(mem)
+        }
+    },
+    0xe1: func(core *Core8080, mem memory.Memory) { //POP H	1		L <- (sp); H <- (sp+1); sp <- sp+2
+        core.SetHL(core.pop(mem))
+    },
+    0xe2: func(core *Core8080, mem memory.Memory) { //JPO adr	3		if PO, PC <- adr
+        if !core.flag(FlagP) {
+            core.PC = core.imm16()
+        }
+    },
+    0xe3: func(core *Core8080, mem memory.Memory) { //XTHL	1		L <-> (SP); H <-> (SP+1)
+        l, h := mem.Read(core.SP), mem.Read(core.SP+1)
+        mem.Write(core.SP, core.L)
+        mem.Write(core.SP+1, core.H)
+        core.H, core.L = h, l
+    },
+    0xe4: func(core *Core8080, mem memory.Memory) { //CPO adr	3		if PO, CALL adr
+        if !core.flag(FlagP) {
+            core.cycles += 6
+            core.call(mem, core.imm16())
+        }
+    },
+    0xe5: func(core *Core8080, mem memory.Memory) { //PUSH H	1		(sp-2)<-L; (sp-1)<-H; sp <- sp - 2
+        core.push(mem, core.HL())
+    },
+    0xe6: func(core *Core8080, mem memory.Memory) { //ANI D8	2	Z, S, P, CY, AC	A <- A & data
+        core.ana(core.op[1])
+    },
+    0xe7: func(core *Core8080, mem memory.Memory) { //RST 4	1		CALL $20
+        core.call(mem, 0x20)
+    },
+    0xe8: func(core *Core8080, mem memory.Memory) { //RPE	1		if PE, RET
+        if core.flag(FlagP) {
+            core.cycles += 6
+            core.ret(mem)
+        }
+    },
+    0xe9: func(core *Core8080, mem memory.Memory) { //PCHL	1		PC.hi <- H; PC.lo <- L
+        core.PC = core.HL()
+    },
+    0xea: func(core *Core8080, mem memory.Memory) { //JPE adr	3		if PE, PC <- adr
+        if core.flag(FlagP) {
+            core.PC = core.imm16()
+        }
+    },
+    0xeb: func(core *Core8080, mem memory.Memory) { //XCHG	1		H <-> D; L <-> E
+        core.H, core.D = core.D, core.H
+        core.L, core.E = core.E, core.L
+    },
+    0xec: func(core *Core8080, mem memory.Memory) { //CPE adr	3		if PE, CALL adr
+        if core.flag(FlagP) {
+            core.cycles += 6
+            core.call(mem, core.imm16())
+        }
+    },
+    0xed: func(core *Core8080, mem memory.Memory) { //-
+        core.call(mem, core.imm16())
+    },
+    0xee: func(core *Core8080, mem memory.Memory) { //XRI D8	2	Z, S, P, CY, AC	A <- A ^ data
+        core.xra(core.op[1])
+    },
+    0xef: func(core *Core8080, mem memory.Memory) { //RST 5	1		CALL $28
+        core.call(mem, 0x28)
+    },
+    0xf0: func(core *Core8080, mem memory.Memory) { //RP	1		if P, RET
+        if !core.flag(FlagS) {
+            core.cycles += 6
+            core.ret(mem)
+        }
+    },
+    0xf1: func(core *Core8080, mem memory.Memory) { //POP PSW	1		flags <- (sp); A <- (sp+1); sp <- sp+2
+        core.A, core.Flags = u16ToHiLowU8(core.pop(mem))
+        core.Flags = core.Flags&0xD7 | 0x02
+    },
+    0xf2: func(core *Core8080, mem memory.Memory) { //JP adr	3		if P=1 PC <- adr
+        if !core.flag(FlagS) {
+            core.PC = core.imm16()
+        }
+    },
+    0xf3: func(core *Core8080, mem memory.Memory) { //DI	1		special
+        core.IntEnable = false
+    },
+    0xf4: func(core *Core8080, mem memory.Memory) { //CP adr	3		if P, CALL adr
+        if !core.flag(FlagS) {
+            core.cycles += 6
+            core.call(mem, core.imm16())
+        }
+    },
+    0xf5: func(core *Core8080, mem memory.Memory) { //PUSH PSW	1		(sp-2)<-flags; (sp-1)<-A; sp <- sp - 2
+        core.push(mem, u8HiLowRoU16(core.A, core.Flags))
+    },
+    0xf6: func(core *Core8080, mem memory.Memory) { //ORI D8	2	Z, S, P, CY, AC	A <- A | data
+        core.ora(core.op[1])
+    },
+    0xf7: func(core *Core8080, mem memory.Memory) { //RST 6	1		CALL $30
+        core.call(mem, 0x30)
+    },
+    0xf8: func(core *Core8080, mem memory.Memory) { //RM	1		if M, RET
+        if core.flag(FlagS) {
+            core.cycles += 6
+            core.ret(mem)
+        }
+    },
+    0xf9: func(core *Core8080, mem memory.Memory) { //SPHL	1		SP=HL
+        core.SP = core.HL()
+    },
+    0xfa: func(core *Core8080, mem memory.Memory) { //JM adr	3		if M, PC <- adr
+        if core.flag(FlagS) {
+            core.PC = core.imm16()
+        }
+    },
+    0xfb: func(core *Core8080, mem memory.Memory) { //EI	1		special
+        core.IntEnable = true
+    },
+    0xfc: func(core *Core8080, mem memory.Memory) { //CM adr	3		if M, CALL adr
+        if core.flag(FlagS) {
+            core.cycles += 6
+            core.call(mem, core.imm16())
+        }
+    },
+    0xfd: func(core *Core8080, mem memory.Memory) { //-
+        core.call(mem, core.imm16())
+    },
+    0xfe: func(core *Core8080, mem memory.Memory) { //CPI D8	2	Z, S, P, CY, AC	A - data
+        core.cmp(core.op[1])
+    },
+    0xff: func(core *Core8080, mem memory.Memory) { //RST 7	1		CALL $38
+        core.call(mem, 0x38)
+    },
 }
@@ -0,0 +1,43 @@
+#!/bin/sh
+# Fetches the per-opcode single-step test vectors for the 8080 into
+# core/testdata/8080, where TestVectors runs them:
+#
+#	core/testdata/fetch-vectors.sh [SOURCE]
+#
+# SOURCE is a git repository or a local directory laid out like the
+# SingleStepTests suites, one file per opcode as v1/00.json .. v1/ff.json,
+# optionally gzipped. It defaults to the SingleStepTests 8080 repository.
+# The files are large, so they stay out of git.
+set -e
+
+src=${1:-https://github.com/SingleStepTests/8080.git}
+dest=$(cd "$(dirname "$0")" && pwd)/8080
+
+tmp=
+trap 'if [ -n "$tmp" ]; then rm -rf "$tmp"; fi' EXIT
+if [ ! -d "$src" ]; then
+	tmp=$(mktemp -d)
+	git clone --depth 1 "$src" "$tmp"
+	src=$tmp
+fi
+vectors=$src
+if [ -d "$src/v1" ]; then
+	vectors=$src/v1
+fi
+
+mkdir -p "$dest"
+n=0
+for f in "$vectors"/*.json "$vectors"/*.json.gz; do
+	[ -e "$f" ] || continue
+	name=$(basename "$f" .gz)
+	case $f in
+	*.gz) gzip -dc "$f" >"$dest/$name" ;;
+	*) cp "$f" "$dest/$name" ;;
+	esac
+	n=$((n + 1))
+done
+if [ "$n" -eq 0 ]; then
+	echo "fetch-vectors: no .json files in $vectors" >&2
+	exit 1
+fi
+echo "fetch-vectors: $n files in $dest"
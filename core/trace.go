@@ -0,0 +1,44 @@
+package core
+
+// TraceEvent is the CPU state just before the instruction at PC executes.
+// Opcode holds the 3 bytes at PC, only Opcodes[Opcode[0]].Size of them
+// belong to the instruction.
+type TraceEvent struct {
+    PC, SP                     uint16
+    Opcode                     [3]uint8
+    A, B, C, D, E, H, L, Flags uint8
+    Cycles                     uint64
+}
+
+// Tracer receives one event per instruction. The event is reused for the
+// next instruction, so a Tracer that keeps it must copy it.
+type Tracer interface {
+    Trace(ev *TraceEvent)
+}
+
+func (core *Core8080) trace() {
+    ev := &core.event
+    ev.PC, ev.SP = core.PC, core.SP
+    ev.Opcode = core.op
+    ev.A, ev.B, ev.C, ev.D, ev.E = core.A, core.B, core.C, core.D, core.E
+    ev.H, ev.L, ev.Flags = core.H, core.L, core.Flags
+    ev.Cycles = core.cycles
+    core.Tracer.Trace(ev)
+}
+
+func (ev *TraceEvent) BC() uint16 {
+    return u8HiLowRoU16(ev.B, ev.C)
+}
+
+func (ev *TraceEvent) DE() uint16 {
+    return u8HiLowRoU16(ev.D, ev.E)
+}
+
+func (ev *TraceEvent) HL() uint16 {
+    return u8HiLowRoU16(ev.H, ev.L)
+}
+
+// Size of the traced instruction in bytes.
+func (ev *TraceEvent) Size() int {
+    return Opcodes[ev.Opcode[0]].Size
+}
@@ -0,0 +1,146 @@
+// Package cpm runs CP/M .COM programs, mainly the classic 8080 test suites,
+// with just enough BDOS for them to print their results.
+package cpm
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+
+    "github.com/siathema/goInvadeSpace/core"
+    "github.com/siathema/goInvadeSpace/memory"
+)
+
+const (
+    // .COM files load and start here.
+    TPA = 0x0100
+    // Programs CALL 5 for BDOS services.
+    bdosEntry = 0x0005
+    // Top of memory as reported through the word at 0006, programs set
+    // their stack from it.
+    memTop = 0xF000
+)
+
+// Suites are what the well known test programs print when every test
+// passed, by file name.
+var Suites = map[string]string{
+    "cpudiag.bin":  "CPU IS OPERATIONAL",
+    "TST8080.COM":  "CPU IS OPERATIONAL",
+    "8080PRE.COM":  "8080 Preliminary tests complete",
+    "CPUTEST.COM":  "CPU TESTS OK",
+    "8080EXM.COM":  "Tests complete",
+    "8080EXER.COM": "Tests complete",
+}
+
+// Passed reports whether output, from a program that ran to its end, shows
+// every test passing: the suite's success message if it's one of Suites,
+// and no mention of an error or failure.
+func Passed(name, output string) bool {
+    if want, ok := Suites[filepath.Base(name)]; ok && !strings.Contains(output, want) {
+        return false
+    }
+    upper := strings.ToUpper(output)
+
+    return !strings.Contains(upper, "ERROR") && !strings.Contains(upper, "FAIL")
+}
+
+// ErrLimit is returned when a program is still running after the
+// instruction limit given to Run.
+var ErrLimit = errors.New("cpm: instruction limit reached")
+
+// Machine is a Core8080 on flat 64K memory with BDOS calls trapped.
+type Machine struct {
+    CPU *core.Core8080
+    Mem *memory.FlatMemory
+    Out io.Writer
+
+    Instructions uint64
+}
+
+// New loads program at 0100 and sets up page zero. Console output goes to
+// out.
+func New(program []uint8, out io.Writer) *Machine {
+    m := &Machine{
+        CPU: core.New(),
+        Mem: memory.NewFlatMemory(),
+        Out: out,
+    }
+    m.Mem.Load(TPA, program)
+    // Warm boot at 0000 ends the run before anything executes there.
+    m.Mem.Data[0x0000] = 0x76
+    // BDOS calls land on a RET once the trap has done the work.
+    m.Mem.Data[bdosEntry] = 0xC9
+    m.Mem.Data[0x0006] = uint8(memTop & 0xFF)
+    m.Mem.Data[0x0007] = uint8(memTop >> 8)
+    m.CPU.PC = TPA
+    m.CPU.SP = memTop
+
+    return m
+}
+
+// Load reads a .COM file.
+func Load(path string, out io.Writer) (*Machine, error) {
+    program, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    if len(program) > memTop-TPA {
+        return nil, fmt.Errorf("cpm: %s is too big for the TPA", path)
+    }
+
+    return New(program, out), nil
+}
+
+// Run executes until the program jumps to 0000 or calls BDOS function 0,
+// or until limit instructions have run when limit is not 0.
+func (m *Machine) Run(limit uint64) error {
+    for {
+        switch m.CPU.PC {
+        case 0x0000:
+            return nil
+        case bdosEntry:
+            if done, err := m.bdos(); done || err != nil {
+                return err
+            }
+        }
+        if limit != 0 && m.Instructions >= limit {
+            return ErrLimit
+        }
+        m.CPU.RunTick(m.Mem)
+        m.Instructions++
+    }
+}
+
+// bdos handles the call in C, reporting true for a system reset.
+func (m *Machine) bdos() (bool, error) {
+    c := m.CPU
+    switch c.C {
+    case 0:
+        return true, nil
+    case 2:
+        _, err := m.Out.Write([]byte{c.E})
+        return false, err
+    case 9:
+        addr := uint16(c.D)<<8 | uint16(c.E)
+        var buf []byte
+        for n := 0; m.Mem.Data[addr] != '$'; n++ {
+            if n == 0x10000 {
+                return false, errors.New("cpm: unterminated string for BDOS 9")
+            }
+            buf = append(buf, m.Mem.Data[addr])
+            addr++
+        }
+        _, err := m.Out.Write(buf)
+        return false, err
+    }
+
+    return false, fmt.Errorf("cpm: unsupported BDOS function %d at PC=%04X", c.C, m.returnAddr())
+}
+
+func (m *Machine) returnAddr() uint16 {
+    sp := m.CPU.SP
+    return uint16(m.Mem.Data[sp+1])<<8 | uint16(m.Mem.Data[sp])
+}
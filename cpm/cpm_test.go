@@ -0,0 +1,104 @@
+package cpm
+
+import (
+    "bytes"
+    "flag"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+var long = flag.Bool("long", false, "also run the slow exerciser suites")
+
+func TestBDOSOutput(t *testing.T) {
+    program := []uint8{
+        0x0e, 0x09, //       MVI C,09
+        0x11, 0x12, 0x01, // LXI D,msg
+        0xcd, 0x05, 0x00, // CALL 0005
+        0x0e, 0x02, //       MVI C,02
+        0x1e, 0x21, //       MVI E,'!'
+        0xcd, 0x05, 0x00, // CALL 0005
+        0xc3, 0x00, 0x00, // JMP 0000
+        'H', 'E', 'L', 'L', 'O', '$', // msg
+    }
+    out := &bytes.Buffer{}
+    m := New(program, out)
+    if err := m.Run(1000); err != nil {
+        t.Fatal(err)
+    }
+    if out.String() != "HELLO!" {
+        t.Errorf("Expected \"HELLO!\", got=%q", out.String())
+    }
+    if m.CPU.SP != memTop {
+        t.Errorf("Expected BDOS calls to return, got SP=%04X", m.CPU.SP)
+    }
+}
+
+func TestLimit(t *testing.T) {
+    // JMP 0100
+    m := New([]uint8{0xc3, 0x00, 0x01}, &bytes.Buffer{})
+    if err := m.Run(50); err != ErrLimit {
+        t.Errorf("Expected ErrLimit, got=%v", err)
+    }
+}
+
+// The suites themselves aren't redistributed with the repo. Fetch them
+// with testdata/fetch-diagnostics.sh or drop the .COM files into testdata,
+// missing ones are skipped.
+func TestDiagnostics(t *testing.T) {
+    tests := []struct {
+        file     string
+        expected string
+        slow     bool
+    }{
+        {"cpudiag.bin", "CPU IS OPERATIONAL", false},
+        {"TST8080.COM", "CPU IS OPERATIONAL", false},
+        {"8080PRE.COM", "8080 Preliminary tests complete", false},
+        {"CPUTEST.COM", "CPU TESTS OK", true},
+        {"8080EXM.COM", "Tests complete", true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.file, func(t *testing.T) {
+            path := filepath.Join("testdata", tt.file)
+            if _, err := os.Stat(path); err != nil {
+                t.Skipf("%s not present, see testdata/fetch-diagnostics.sh", path)
+            }
+            if tt.slow && !*long {
+                t.Skip("slow suite, run with -long")
+            }
+            out := &bytes.Buffer{}
+            m, err := Load(path, out)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if err := m.Run(0); err != nil {
+                t.Fatalf("%v\noutput so far:\n%s", err, out.String())
+            }
+            if !strings.Contains(out.String(), tt.expected) ||
+                strings.Contains(strings.ToUpper(out.String()), "ERROR") {
+                t.Errorf("Expected %q without errors, got:\n%s", tt.expected, out.String())
+            }
+        })
+    }
+}
+
+func TestPassed(t *testing.T) {
+    tests := []struct {
+        name   string
+        output string
+        passed bool
+    }{
+        {"testdata/TST8080.COM", "MICROCOSM ASSOCIATES 8080/8085 CPU DIAGNOSTIC\r\n CPU IS OPERATIONAL", true},
+        {"TST8080.COM", "CPU HAS FAILED! ERROR EXIT=", false},
+        {"8080EXM.COM", "aluop nn....  ERROR **** crc expected:9e922f9e found:...\r\nTests complete", false},
+        {"8080EXM.COM", "dad <b,d,h,sp>................  PASS! crc is:14474ba6", false},
+        {"mine.com", "all good", true},
+    }
+    for _, tt := range tests {
+        if got := Passed(tt.name, tt.output); got != tt.passed {
+            t.Errorf("%s %q: Expected %v, got=%v", tt.name, tt.output, tt.passed, got)
+        }
+    }
+}
@@ -0,0 +1,38 @@
+#!/bin/sh
+# Fetches the CP/M diagnostic suites into cpm/testdata, where
+# TestDiagnostics runs them:
+#
+#	cpm/testdata/fetch-diagnostics.sh [SOURCE]
+#
+# SOURCE is a git repository or a local directory holding TST8080.COM,
+# 8080PRE.COM, CPUTEST.COM and 8080EXM.COM anywhere below it, in any case.
+# It defaults to superzazu/8080, which keeps them under cpu_tests; that
+# default hasn't been checked from here, so pass another copy if it moved.
+# The suites aren't ours to redistribute, so they stay out of git.
+set -e
+
+src=${1:-https://github.com/superzazu/8080.git}
+dest=$(cd "$(dirname "$0")" && pwd)
+
+tmp=
+trap 'if [ -n "$tmp" ]; then rm -rf "$tmp"; fi' EXIT
+if [ ! -d "$src" ]; then
+	tmp=$(mktemp -d)
+	git clone --depth 1 "$src" "$tmp"
+	src=$tmp
+fi
+
+n=0
+for name in TST8080.COM 8080PRE.COM CPUTEST.COM 8080EXM.COM; do
+	f=$(find "$src" -type f -iname "$name" | head -n 1)
+	if [ -z "$f" ]; then
+		echo "fetch-diagnostics: no $name in $src" >&2
+		continue
+	fi
+	cp "$f" "$dest/$name"
+	n=$((n + 1))
+done
+if [ "$n" -eq 0 ]; then
+	exit 1
+fi
+echo "fetch-diagnostics: $n suites in $dest"
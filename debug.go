@@ -0,0 +1,56 @@
+package main
+
+import (
+    "fmt"
+    "os"
+
+    "github.com/siathema/goInvadeSpace/debugger"
+    "github.com/siathema/goInvadeSpace/gdbstub"
+    "github.com/siathema/goInvadeSpace/symbols"
+)
+
+func debugCmd(args []string) error {
+    fs := newFlagSet("debug", "")
+    mf := addMachineFlags(fs)
+    symFile := fs.String("syms", "", "extra symbol file")
+    gdbAddr := fs.String("gdb", "", "serve the GDB remote protocol on this address instead, e.g. localhost:1234")
+    if err := parse(fs, args); err != nil {
+        return err
+    }
+    if fs.NArg() != 0 {
+        return usageError("debug takes no arguments")
+    }
+
+    game, rom, err := mf.load()
+    if err != nil {
+        return err
+    }
+    m := game.New(rom)
+    if err := mf.setDIPs(m); err != nil {
+        return err
+    }
+    stopTrace, err := mf.startTrace(game, m)
+    if err != nil {
+        return err
+    }
+    defer stopTrace()
+
+    if *gdbAddr != "" {
+        fmt.Fprintf(os.Stderr, "waiting for gdb on %s\n", *gdbAddr)
+        return gdbstub.NewMachine(m).ListenAndServe(*gdbAddr)
+    }
+    d := debugger.NewMachine(m)
+    d.Syms = game.Syms()
+    if *symFile != "" {
+        t, err := symbols.Load(*symFile)
+        if err != nil {
+            return err
+        }
+        d.Syms.Merge(t)
+    }
+    if err := d.Run(os.Stdin, os.Stdout); err != nil {
+        return err
+    }
+
+    return stopTrace()
+}
@@ -0,0 +1,197 @@
+package debugger
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+
+    "github.com/siathema/goInvadeSpace/core"
+)
+
+// One "REG OP VALUE" test, e.g. "A == 3F" or "HL >= 2400".
+type term struct {
+    reg   string
+    op    string
+    value uint16
+}
+
+// Condition is a set of terms that must all hold for a breakpoint to fire.
+type Condition struct {
+    terms []term
+    text  string
+}
+
+var condOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// ParseCondition parses terms joined with "&&". Register names are the ones
+// accepted by RegValue and values are hex.
+func ParseCondition(s string) (*Condition, error) {
+    cond := &Condition{text: strings.TrimSpace(s)}
+    for _, part := range strings.Split(s, "&&") {
+        part = strings.TrimSpace(part)
+        t, err := parseTerm(part)
+        if err != nil {
+            return nil, err
+        }
+        cond.terms = append(cond.terms, t)
+    }
+
+    return cond, nil
+}
+
+func parseTerm(s string) (term, error) {
+    for _, op := range condOps {
+        i := strings.Index(s, op)
+        if i < 0 {
+            continue
+        }
+        reg := strings.ToUpper(strings.TrimSpace(s[:i]))
+        if _, ok := RegValue(&core.Core8080{}, reg); !ok {
+            return term{}, fmt.Errorf("unknown register %q", reg)
+        }
+        value, err := parseHex(strings.TrimSpace(s[i+len(op):]))
+        if err != nil {
+            return term{}, err
+        }
+        return term{reg: reg, op: op, value: value}, nil
+    }
+
+    return term{}, fmt.Errorf("bad condition %q, want REG OP VALUE", s)
+}
+
+// Eval reports whether every term holds for the current CPU state.
+func (cond *Condition) Eval(cpu *core.Core8080) bool {
+    for _, t := range cond.terms {
+        v, _ := RegValue(cpu, t.reg)
+        var ok bool
+        switch t.op {
+        case "==":
+            ok = v == t.value
+        case "!=":
+            ok = v != t.value
+        case "<":
+            ok = v < t.value
+        case "<=":
+            ok = v <= t.value
+        case ">":
+            ok = v > t.value
+        case ">=":
+            ok = v >= t.value
+        }
+        if !ok {
+            return false
+        }
+    }
+
+    return true
+}
+
+func (cond *Condition) String() string {
+    return cond.text
+}
+
+var flagBits = map[string]uint8{
+    "S": core.FlagS, "Z": core.FlagZ, "AC": core.FlagAC, "P": core.FlagP, "CY": core.FlagCY,
+}
+
+// RegValue reads a register, register pair or single flag by name.
+func RegValue(cpu *core.Core8080, name string) (uint16, bool) {
+    switch name {
+    case "A":
+        return uint16(cpu.A), true
+    case "B":
+        return uint16(cpu.B), true
+    case "C":
+        return uint16(cpu.C), true
+    case "D":
+        return uint16(cpu.D), true
+    case "E":
+        return uint16(cpu.E), true
+    case "H":
+        return uint16(cpu.H), true
+    case "L":
+        return uint16(cpu.L), true
+    case "F":
+        return uint16(cpu.Flags), true
+    case "BC":
+        return uint16(cpu.B)<<8 | uint16(cpu.C), true
+    case "DE":
+        return uint16(cpu.D)<<8 | uint16(cpu.E), true
+    case "HL":
+        return uint16(cpu.H)<<8 | uint16(cpu.L), true
+    case "PSW":
+        return uint16(cpu.A)<<8 | uint16(cpu.Flags), true
+    case "SP":
+        return cpu.SP, true
+    case "PC":
+        return cpu.PC, true
+    }
+    if bit, ok := flagBits[name]; ok {
+        if cpu.Flags&bit != 0 {
+            return 1, true
+        }
+        return 0, true
+    }
+
+    return 0, false
+}
+
+// SetReg is the inverse of RegValue.
+func SetReg(cpu *core.Core8080, name string, v uint16) bool {
+    switch name {
+    case "A":
+        cpu.A = uint8(v)
+    case "B":
+        cpu.B = uint8(v)
+    case "C":
+        cpu.C = uint8(v)
+    case "D":
+        cpu.D = uint8(v)
+    case "E":
+        cpu.E = uint8(v)
+    case "H":
+        cpu.H = uint8(v)
+    case "L":
+        cpu.L = uint8(v)
+    case "F":
+        cpu.Flags = uint8(v)
+    case "BC":
+        cpu.B, cpu.C = uint8(v>>8), uint8(v)
+    case "DE":
+        cpu.D, cpu.E = uint8(v>>8), uint8(v)
+    case "HL":
+        cpu.H, cpu.L = uint8(v>>8), uint8(v)
+    case "PSW":
+        cpu.A, cpu.Flags = uint8(v>>8), uint8(v)
+    case "SP":
+        cpu.SP = v
+    case "PC":
+        cpu.PC = v
+    default:
+        bit, ok := flagBits[name]
+        if !ok {
+            return false
+        }
+        if v != 0 {
+            cpu.Flags |= bit
+        } else {
+            cpu.Flags &^= bit
+        }
+    }
+
+    return true
+}
+
+// Addresses and data are always hex, with or without a 0x, $ or h marker.
+func parseHex(s string) (uint16, error) {
+    t := strings.ToLower(s)
+    t = strings.TrimPrefix(t, "0x")
+    t = strings.TrimPrefix(t, "$")
+    t = strings.TrimSuffix(t, "h")
+    v, err := strconv.ParseUint(t, 16, 16)
+    if err != nil {
+        return 0, fmt.Errorf("bad hex value %q", s)
+    }
+
+    return uint16(v), nil
+}
@@ -0,0 +1,709 @@
+package debugger
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "io"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/siathema/goInvadeSpace/core"
+    "github.com/siathema/goInvadeSpace/machine"
+    "github.com/siathema/goInvadeSpace/memory"
+    "github.com/siathema/goInvadeSpace/symbols"
+)
+
+// Instructions run by continue/until before control comes back to the
+// prompt, so a ROM spinning in a wait loop can't hang the session.
+const DefaultRunLimit = 10000000
+
+type Breakpoint struct {
+    Addr uint16
+    Cond *Condition
+    Hits int
+}
+
+// Watchpoint stops execution (or just logs, if Log is set) after an
+// instruction touches memory in Lo..Hi.
+type Watchpoint struct {
+    ID     int
+    Lo, Hi uint16
+    Access memory.Access
+    Log    bool
+    Hits   int
+}
+
+// A watched access seen while executing the instruction at PC.
+type WatchHit struct {
+    Watch  *Watchpoint
+    Access memory.Access
+    Addr   uint16
+    Data   uint8
+    PC     uint16
+}
+
+// Debugger drives a Core8080 and its memory from text commands. Mem is used
+// as is for inspection, the CPU runs against a hooked view of it so
+// watchpoints don't fire on the debugger's own dumps.
+type Debugger struct {
+    CPU      *core.Core8080
+    Mem      memory.Memory
+    Syms     *symbols.Table
+    RunLimit int
+    // Machine is set by NewMachine, without it there are no interrupts and
+    // no reverse execution.
+    Machine *machine.Machine
+
+    bus         *memory.HookedMemory
+    breakpoints map[uint16]*Breakpoint
+    watchpoints []*Watchpoint
+    hits        []WatchHit
+    pc          uint16
+    out         io.Writer
+
+    count       uint64
+    checkpoints []*checkpoint
+    scratch     machine.State
+}
+
+func New(cpu *core.Core8080, mem memory.Memory) *Debugger {
+    d := &Debugger{
+        CPU:         cpu,
+        Mem:         mem,
+        Syms:        symbols.New(),
+        RunLimit:    DefaultRunLimit,
+        bus:         memory.NewHookedMemory(mem),
+        breakpoints: make(map[uint16]*Breakpoint),
+        out:         io.Discard,
+    }
+
+    return d
+}
+
+var errQuit = errors.New("quit")
+
+// Run reads commands from in until EOF or "quit", writing all output to out.
+func (d *Debugger) Run(in io.Reader, out io.Writer) error {
+    d.out = out
+    scanner := bufio.NewScanner(in)
+    d.printState()
+    fmt.Fprint(out, "> ")
+    last := ""
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        // An empty line repeats the previous command like most debuggers.
+        if line == "" {
+            line = last
+        }
+        err := d.Exec(line)
+        if err == errQuit {
+            return nil
+        } else if err != nil {
+            fmt.Fprintf(out, "error: %v\n", err)
+        }
+        last = line
+        fmt.Fprint(out, "> ")
+    }
+
+    return scanner.Err()
+}
+
+// Exec runs a single command line.
+func (d *Debugger) Exec(line string) error {
+    args := strings.Fields(line)
+    if len(args) == 0 {
+        return nil
+    }
+    cmd, args := strings.ToLower(args[0]), args[1:]
+
+    switch cmd {
+    case "s", "step":
+        n, err := countArg(args, 1)
+        if err != nil {
+            return err
+        }
+        d.Step(n)
+        d.printState()
+    case "c", "continue":
+        n, err := countArg(args, d.RunLimit)
+        if err != nil {
+            return err
+        }
+        d.report(d.Continue(n))
+    case "rs", "reverse-step":
+        n, err := countArg(args, 1)
+        if err != nil {
+            return err
+        }
+        back, err := d.ReverseStep(n)
+        if back > 0 {
+            fmt.Fprintf(d.out, "back %d instructions\n", back)
+            d.printState()
+        }
+        return err
+    case "rc", "reverse-continue":
+        bp, back, err := d.ReverseContinue()
+        if bp != nil {
+            fmt.Fprintf(d.out, "breakpoint %04X %d instructions back\n", bp.Addr, back)
+            d.printState()
+        } else if back > 0 {
+            fmt.Fprintf(d.out, "back %d instructions\n", back)
+            d.printState()
+        }
+        return err
+    case "u", "until":
+        if len(args) != 1 {
+            return errors.New("usage: until ADDR")
+        }
+        addr, err := d.parseAddr(args[0])
+        if err != nil {
+            return err
+        }
+        d.report(d.RunTo(addr, d.RunLimit))
+    case "b", "break":
+        if len(args) == 0 {
+            d.listBreakpoints()
+            return nil
+        }
+        return d.breakCmd(args)
+    case "del", "delete":
+        if len(args) != 1 {
+            return errors.New("usage: delete ADDR|all")
+        }
+        if args[0] == "all" {
+            d.breakpoints = make(map[uint16]*Breakpoint)
+            return nil
+        }
+        addr, err := d.parseAddr(args[0])
+        if err != nil {
+            return err
+        }
+        if !d.RemoveBreakpoint(addr) {
+            return fmt.Errorf("no breakpoint at %04X", addr)
+        }
+    case "wa", "watch":
+        if len(args) == 0 {
+            d.listWatchpoints()
+            return nil
+        }
+        return d.watchCmd(args)
+    case "unwatch":
+        if len(args) != 1 {
+            return errors.New("usage: unwatch ID|all")
+        }
+        if args[0] == "all" {
+            d.bus.UnwatchAll()
+            d.watchpoints = nil
+            return nil
+        }
+        id, err := strconv.Atoi(args[0])
+        if err != nil || !d.RemoveWatchpoint(id) {
+            return fmt.Errorf("no watchpoint %q", args[0])
+        }
+    case "r", "regs":
+        d.printRegs()
+    case "set":
+        if len(args) != 2 {
+            return errors.New("usage: set REG VALUE")
+        }
+        v, err := parseHex(args[1])
+        if err != nil {
+            return err
+        }
+        if !SetReg(d.CPU, strings.ToUpper(args[0]), v) {
+            return fmt.Errorf("unknown register %q", args[0])
+        }
+        d.rebase()
+        d.printRegs()
+    case "x", "dump":
+        return d.dumpCmd(args)
+    case "w", "write":
+        return d.writeCmd(args)
+    case "l", "dis":
+        return d.disCmd(args)
+    case "sym":
+        return d.symCmd(args)
+    case "h", "help", "?":
+        fmt.Fprint(d.out, helpText)
+    case "q", "quit", "exit":
+        return errQuit
+    default:
+        return fmt.Errorf("unknown command %q, try help", cmd)
+    }
+
+    return nil
+}
+
+const helpText = `commands (addresses and values are hex, counts are decimal):
+  s, step [N]             execute N instructions (default 1)
+  c, continue [N]         run until a breakpoint, at most N instructions
+  u, until ADDR           run until PC reaches ADDR
+  rs, reverse-step [N]    go back N instructions (default 1)
+  rc, reverse-continue    go back to the last breakpoint passed
+  b, break                list breakpoints
+  b, break ADDR [if EXPR] break at ADDR, optionally only when EXPR holds
+                          e.g. "b 1A32 if A == 3F && CY == 1"
+  del, delete ADDR|all    remove breakpoints
+  wa, watch               list watchpoints
+  wa, watch ADDR[-END] [r|w|rw] [log]
+                          stop after an instruction reads or writes the
+                          range (default w), or only log it with "log"
+  unwatch ID|all          remove watchpoints
+  r, regs                 show registers and flags
+  set REG VALUE           change a register, pair or flag
+  x, dump ADDR [LEN]      hex dump LEN bytes (default 40)
+  w, write ADDR BYTE...   write bytes to memory
+  l, dis [ADDR] [N]       disassemble N instructions (default around PC)
+  sym FILE                load symbols, names work anywhere an ADDR does
+  sym NAME|ADDR           look up a symbol
+  q, quit                 leave the debugger
+`
+
+// Step executes n instructions regardless of breakpoints and watchpoints,
+// watchpoint hits are still printed.
+func (d *Debugger) Step(n int) {
+    for i := 0; i < n; i++ {
+        d.tick()
+    }
+}
+
+// Continue executes up to limit instructions, stopping before any
+// instruction with an enabled breakpoint or after one that hit a breaking
+// watchpoint. The instruction at the starting PC always runs so continuing
+// from a breakpoint makes progress.
+func (d *Debugger) Continue(limit int) (*Breakpoint, int) {
+    for i := 0; i < limit; i++ {
+        if i > 0 {
+            if bp := d.AtBreakpoint(); bp != nil {
+                return bp, i
+            }
+        }
+        if d.tick() {
+            return nil, i + 1
+        }
+    }
+
+    return nil, limit
+}
+
+// tick runs one instruction and reports whether a breaking watchpoint fired.
+func (d *Debugger) tick() bool {
+    d.pc = d.CPU.PC
+    d.hits = d.hits[:0]
+    if err := d.checkpoint(); err != nil {
+        fmt.Fprintf(d.out, "warning: %v\n", err)
+    }
+    // Fetch outside the hooked bus, read watchpoints are for data accesses
+    // and RunTick always fetches 3 bytes whatever the instruction size.
+    d.CPU.ExecuteOpcode(d.fetch(d.CPU.PC), d.bus)
+    if d.Machine != nil {
+        d.Machine.Raise(d.bus)
+    }
+    d.count++
+    stop := false
+    for _, h := range d.hits {
+        d.printHit(h)
+        if !h.Watch.Log {
+            stop = true
+        }
+    }
+
+    return stop
+}
+
+// Hits returns the watched accesses made by the last instruction executed.
+func (d *Debugger) Hits() []WatchHit {
+    return d.hits
+}
+
+// RunTo is Continue with a one-off breakpoint at addr.
+func (d *Debugger) RunTo(addr uint16, limit int) (*Breakpoint, int) {
+    temp := false
+    if _, ok := d.breakpoints[addr]; !ok {
+        d.breakpoints[addr] = &Breakpoint{Addr: addr}
+        temp = true
+    }
+    bp, n := d.Continue(limit)
+    if temp {
+        delete(d.breakpoints, addr)
+    }
+
+    return bp, n
+}
+
+// AtBreakpoint returns the breakpoint at PC if its condition holds,
+// counting it as a hit.
+func (d *Debugger) AtBreakpoint() *Breakpoint {
+    bp, ok := d.breakpoints[d.CPU.PC]
+    if !ok {
+        return nil
+    }
+    if bp.Cond != nil && !bp.Cond.Eval(d.CPU) {
+        return nil
+    }
+    bp.Hits++
+
+    return bp
+}
+
+// SetBreakpoint adds or replaces the breakpoint at addr. cond may be nil.
+func (d *Debugger) SetBreakpoint(addr uint16, cond *Condition) {
+    d.breakpoints[addr] = &Breakpoint{Addr: addr, Cond: cond}
+}
+
+func (d *Debugger) RemoveBreakpoint(addr uint16) bool {
+    if _, ok := d.breakpoints[addr]; !ok {
+        return false
+    }
+    delete(d.breakpoints, addr)
+
+    return true
+}
+
+func (d *Debugger) breakCmd(args []string) error {
+    addr, err := d.parseAddr(args[0])
+    if err != nil {
+        return err
+    }
+    var cond *Condition
+    if len(args) > 1 {
+        if strings.ToLower(args[1]) != "if" || len(args) < 3 {
+            return errors.New("usage: break ADDR [if EXPR]")
+        }
+        cond, err = ParseCondition(strings.Join(args[2:], " "))
+        if err != nil {
+            return err
+        }
+    }
+    d.SetBreakpoint(addr, cond)
+    fmt.Fprintf(d.out, "breakpoint at %04X\n", addr)
+
+    return nil
+}
+
+func (d *Debugger) listBreakpoints() {
+    if len(d.breakpoints) == 0 {
+        fmt.Fprintln(d.out, "no breakpoints")
+        return
+    }
+    addrs := make([]int, 0, len(d.breakpoints))
+    for addr := range d.breakpoints {
+        addrs = append(addrs, int(addr))
+    }
+    sort.Ints(addrs)
+    for _, addr := range addrs {
+        bp := d.breakpoints[uint16(addr)]
+        fmt.Fprintf(d.out, "%04X %s hits=%d", bp.Addr, d.Syms.Format(bp.Addr), bp.Hits)
+        if bp.Cond != nil {
+            fmt.Fprintf(d.out, " if %s", bp.Cond)
+        }
+        fmt.Fprintln(d.out)
+    }
+}
+
+// AddWatchpoint watches lo..hi for the given kind of access.
+func (d *Debugger) AddWatchpoint(lo, hi uint16, access memory.Access, log bool) *Watchpoint {
+    wp := &Watchpoint{Lo: lo, Hi: hi, Access: access, Log: log}
+    wp.ID = d.bus.Watch(lo, hi, access, func(a memory.Access, addr uint16, data uint8) {
+        wp.Hits++
+        d.hits = append(d.hits, WatchHit{Watch: wp, Access: a, Addr: addr, Data: data, PC: d.pc})
+    })
+    d.watchpoints = append(d.watchpoints, wp)
+
+    return wp
+}
+
+func (d *Debugger) RemoveWatchpoint(id int) bool {
+    for i, wp := range d.watchpoints {
+        if wp.ID == id {
+            d.bus.Unwatch(id)
+            d.watchpoints = append(d.watchpoints[:i], d.watchpoints[i+1:]...)
+            return true
+        }
+    }
+
+    return false
+}
+
+func (d *Debugger) watchCmd(args []string) error {
+    lo, hi, err := d.parseRange(args[0])
+    if err != nil {
+        return err
+    }
+    access := memory.AccessWrite
+    log := false
+    for _, arg := range args[1:] {
+        switch strings.ToLower(arg) {
+        case "r":
+            access = memory.AccessRead
+        case "w":
+            access = memory.AccessWrite
+        case "rw":
+            access = memory.AccessReadWrite
+        case "log":
+            log = true
+        default:
+            return errors.New("usage: watch ADDR[-END] [r|w|rw] [log]")
+        }
+    }
+    wp := d.AddWatchpoint(lo, hi, access, log)
+    fmt.Fprintf(d.out, "watchpoint %d on %04X-%04X %s\n", wp.ID, wp.Lo, wp.Hi, wp.Access)
+
+    return nil
+}
+
+func (d *Debugger) listWatchpoints() {
+    if len(d.watchpoints) == 0 {
+        fmt.Fprintln(d.out, "no watchpoints")
+        return
+    }
+    for _, wp := range d.watchpoints {
+        mode := "break"
+        if wp.Log {
+            mode = "log"
+        }
+        fmt.Fprintf(d.out, "%d: %04X-%04X %s %s hits=%d\n",
+            wp.ID, wp.Lo, wp.Hi, wp.Access, mode, wp.Hits)
+    }
+}
+
+func (d *Debugger) printHit(h WatchHit) {
+    fmt.Fprintf(d.out, "watch %d: %s %s = %02X at PC=%04X (%s)\n",
+        h.Watch.ID, h.Access, d.Syms.Format(h.Addr), h.Data, h.PC, d.Syms.Format(h.PC))
+}
+
+func (d *Debugger) report(bp *Breakpoint, n int) {
+    if bp != nil {
+        fmt.Fprintf(d.out, "breakpoint %04X after %d instructions\n", bp.Addr, n)
+    } else {
+        fmt.Fprintf(d.out, "stopped after %d instructions\n", n)
+    }
+    d.printState()
+}
+
+func (d *Debugger) dumpCmd(args []string) error {
+    if len(args) == 0 || len(args) > 2 {
+        return errors.New("usage: dump ADDR [LEN]")
+    }
+    addr, err := d.parseAddr(args[0])
+    if err != nil {
+        return err
+    }
+    length := uint16(0x40)
+    if len(args) == 2 {
+        if length, err = parseHex(args[1]); err != nil {
+            return err
+        }
+    }
+    // An int counter, a uint16 one would wrap for lengths past FFF0.
+    for off := 0; off < int(length); off += 16 {
+        fmt.Fprintf(d.out, "%04X:", addr+uint16(off))
+        for i := off; i < off+16 && i < int(length); i++ {
+            fmt.Fprintf(d.out, " %02X", d.Mem.Read(addr+uint16(i)))
+        }
+        fmt.Fprintln(d.out)
+    }
+
+    return nil
+}
+
+func (d *Debugger) writeCmd(args []string) error {
+    if len(args) < 2 {
+        return errors.New("usage: write ADDR BYTE...")
+    }
+    addr, err := d.parseAddr(args[0])
+    if err != nil {
+        return err
+    }
+    // Even a partial write changes what replaying would give.
+    defer d.rebase()
+    for i, arg := range args[1:] {
+        v, err := parseHex(arg)
+        if err != nil {
+            return err
+        }
+        if v > 0xFF {
+            return fmt.Errorf("%q is not a byte", arg)
+        }
+        if err := d.Mem.Write(addr+uint16(i), uint8(v)); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+func (d *Debugger) disCmd(args []string) error {
+    n := 10
+    if len(args) > 2 {
+        return errors.New("usage: dis [ADDR] [N]")
+    }
+    if len(args) == 2 {
+        v, err := strconv.Atoi(args[1])
+        if err != nil || v <= 0 {
+            return fmt.Errorf("bad count %q", args[1])
+        }
+        n = v
+    }
+    start := d.backtrack(d.CPU.PC, 4)
+    if len(args) >= 1 {
+        addr, err := d.parseAddr(args[0])
+        if err != nil {
+            return err
+        }
+        start = addr
+    }
+    d.disassemble(start, n)
+
+    return nil
+}
+
+func (d *Debugger) symCmd(args []string) error {
+    if len(args) != 1 {
+        return errors.New("usage: sym FILE|NAME|ADDR")
+    }
+    if addr, ok := d.Syms.Addr(args[0]); ok {
+        fmt.Fprintf(d.out, "%s = %04X\n", args[0], addr)
+        return nil
+    }
+    if addr, err := parseHex(args[0]); err == nil {
+        fmt.Fprintf(d.out, "%04X = %s\n", addr, d.Syms.Format(addr))
+        return nil
+    }
+    t, err := symbols.Load(args[0])
+    if err != nil {
+        return err
+    }
+    d.Syms.Merge(t)
+    fmt.Fprintf(d.out, "loaded %d symbols\n", t.Len())
+
+    return nil
+}
+
+func (d *Debugger) fetch(addr uint16) []uint8 {
+    return []uint8{d.Mem.Read(addr), d.Mem.Read(addr + 1), d.Mem.Read(addr + 2)}
+}
+
+func (d *Debugger) disassemble(addr uint16, n int) {
+    for i := 0; i < n; i++ {
+        opcode := d.fetch(addr)
+        if name, ok := d.Syms.Symbol(addr); ok {
+            fmt.Fprintf(d.out, "%s:\n", name)
+        }
+        text, size := core.DisassembleSym(opcode, d.Syms)
+        marker := "  "
+        if addr == d.CPU.PC {
+            marker = "=>"
+        } else if _, ok := d.breakpoints[addr]; ok {
+            marker = "* "
+        }
+        raw := ""
+        for j := 0; j < size; j++ {
+            raw += fmt.Sprintf("%02X ", opcode[j])
+        }
+        fmt.Fprintf(d.out, "%s %04X  %-9s %s\n", marker, addr, raw, text)
+        addr += uint16(size)
+    }
+}
+
+// backtrack finds an address up to count instructions before pc whose
+// decoding lines up with pc. 8080 code can't be decoded backwards reliably,
+// so this tries every start point up to 3*count bytes back and takes the
+// furthest one that lands exactly on pc.
+func (d *Debugger) backtrack(pc uint16, count int) uint16 {
+    for back := 3 * count; back > 0; back-- {
+        if int(pc) < back {
+            continue
+        }
+        addr := pc - uint16(back)
+        steps := 0
+        for addr < pc && steps < count {
+            _, size := core.Disassemble(d.fetch(addr))
+            addr += uint16(size)
+            steps++
+        }
+        if addr == pc && steps == count {
+            return pc - uint16(back)
+        }
+    }
+
+    return pc
+}
+
+// FormatFlags decodes the status byte, upper case letters are set flags.
+func FormatFlags(f uint8) string {
+    names := []struct {
+        bit  uint8
+        name string
+    }{
+        {core.FlagS, "s"}, {core.FlagZ, "z"}, {core.FlagAC, "ac"}, {core.FlagP, "p"}, {core.FlagCY, "cy"},
+    }
+    parts := make([]string, len(names))
+    for i, n := range names {
+        if f&n.bit != 0 {
+            parts[i] = strings.ToUpper(n.name)
+        } else {
+            parts[i] = n.name
+        }
+    }
+
+    return strings.Join(parts, " ")
+}
+
+func (d *Debugger) printRegs() {
+    c := d.CPU
+    fmt.Fprintf(d.out, "A=%02X BC=%02X%02X DE=%02X%02X HL=%02X%02X SP=%04X PC=%04X F=%02X [%s]\n",
+        c.A, c.B, c.C, c.D, c.E, c.H, c.L, c.SP, c.PC, c.Flags, FormatFlags(c.Flags))
+}
+
+func (d *Debugger) printState() {
+    d.printRegs()
+    if _, off, ok := d.Syms.Nearest(d.CPU.PC); ok && off != 0 {
+        fmt.Fprintf(d.out, "in %s\n", d.Syms.Format(d.CPU.PC))
+    }
+    d.disassemble(d.CPU.PC, 1)
+}
+
+// "2000", "numCoins" or "2000-20FF"
+func (d *Debugger) parseRange(s string) (uint16, uint16, error) {
+    loText, hiText, found := strings.Cut(s, "-")
+    lo, err := d.parseAddr(loText)
+    if err != nil {
+        return 0, 0, err
+    }
+    if !found {
+        return lo, lo, nil
+    }
+    hi, err := d.parseAddr(hiText)
+    if err != nil {
+        return 0, 0, err
+    }
+    if hi < lo {
+        return 0, 0, fmt.Errorf("bad range %q", s)
+    }
+
+    return lo, hi, nil
+}
+
+// Addresses can be given as hex or as a symbol name.
+func (d *Debugger) parseAddr(s string) (uint16, error) {
+    if addr, ok := d.Syms.Addr(s); ok {
+        return addr, nil
+    }
+
+    return parseHex(s)
+}
+
+func countArg(args []string, def int) (int, error) {
+    if len(args) == 0 {
+        return def, nil
+    }
+    n, err := strconv.Atoi(args[0])
+    if err != nil || n <= 0 {
+        return 0, fmt.Errorf("bad count %q", args[0])
+    }
+
+    return n, nil
+}
@@ -0,0 +1,308 @@
+package debugger
+
+import (
+    "bytes"
+    "regexp"
+    "strings"
+    "testing"
+
+    "github.com/siathema/goInvadeSpace/core"
+    "github.com/siathema/goInvadeSpace/machine"
+    "github.com/siathema/goInvadeSpace/memory"
+)
+
+func newTestDebugger(program []uint8) *Debugger {
+    rom := make([]uint8, memory.Kilobytes(8))
+    copy(rom, program)
+
+    return New(core.New(), memory.NewMainMemory(rom))
+}
+
+func TestStepAndBreakpoints(t *testing.T) {
+    // MVI B,01; MVI C,02; NOP; NOP; NOP
+    d := newTestDebugger([]uint8{0x06, 0x01, 0x0e, 0x02, 0x00, 0x00, 0x00})
+
+    d.Step(1)
+    if d.CPU.PC != 2 || d.CPU.B != 1 {
+        t.Errorf("Expected PC=2 B=1, got=(PC=%d B=%d)", d.CPU.PC, d.CPU.B)
+    }
+
+    d.SetBreakpoint(0x0005, nil)
+    bp, n := d.Continue(100)
+    if bp == nil || bp.Addr != 0x0005 || n != 2 {
+        t.Fatalf("Expected breakpoint at 0005 after 2, got=(%v, %d)", bp, n)
+    }
+    if d.CPU.C != 2 {
+        t.Errorf("Expected C=2, got=%d", d.CPU.C)
+    }
+
+    // Continuing from a breakpoint has to move past it.
+    bp, n = d.Continue(1)
+    if bp != nil || d.CPU.PC != 6 {
+        t.Errorf("Expected to leave breakpoint, got=(%v, PC=%d)", bp, d.CPU.PC)
+    }
+}
+
+func TestConditionalBreakpoint(t *testing.T) {
+    // MVI B,00; MVI B,3F; NOP; MVI B,3F; NOP
+    d := newTestDebugger([]uint8{0x06, 0x00, 0x06, 0x3f, 0x00, 0x06, 0x3f, 0x00})
+    cond, err := ParseCondition("B == 3F && PC >= 5")
+    if err != nil {
+        t.Fatal(err)
+    }
+    d.SetBreakpoint(0x0004, cond)
+    d.SetBreakpoint(0x0007, cond)
+
+    bp, _ := d.Continue(100)
+    if bp == nil || bp.Addr != 0x0007 {
+        t.Errorf("Expected breakpoint at 0007, got=%v", bp)
+    }
+
+    if _, err := ParseCondition("Q == 1"); err == nil {
+        t.Errorf("Expected error for unknown register")
+    }
+}
+
+func TestCommands(t *testing.T) {
+    d := newTestDebugger([]uint8{0x06, 0xab, 0x00, 0x00})
+    in := strings.NewReader(strings.Join([]string{
+        "step",
+        "w 2000 de ad",
+        "x 2000 2",
+        "set hl 2400",
+        "until 3",
+        "bogus",
+        "quit",
+        "step",
+    }, "\n"))
+    out := &bytes.Buffer{}
+    if err := d.Run(in, out); err != nil {
+        t.Fatal(err)
+    }
+
+    for _, want := range []string{
+        "2000: DE AD",
+        "HL=2400",
+        "breakpoint 0003 after 1 instructions",
+        "unknown command",
+    } {
+        if !strings.Contains(out.String(), want) {
+            t.Errorf("Expected output to contain %q, got=%s", want, out.String())
+        }
+    }
+    if d.CPU.PC != 3 {
+        t.Errorf("Expected quit to stop at PC=3, got=%d", d.CPU.PC)
+    }
+}
+
+func TestDumpWholeAddressSpace(t *testing.T) {
+    d := newTestDebugger([]uint8{0xc3})
+    out := &bytes.Buffer{}
+    if err := d.Run(strings.NewReader("x 0000 FFFF\n"), out); err != nil {
+        t.Fatal(err)
+    }
+
+    // FFFF bytes is 4095 full rows and one of 15, then it has to stop.
+    rows := len(regexp.MustCompile(`[0-9A-F]{4}:( [0-9A-F]{2})+\n`).FindAllString(out.String(), -1))
+    if rows != 4096 {
+        t.Errorf("Expected 4096 rows, got=%d", rows)
+    }
+    if !strings.Contains(out.String(), "0000: C3 00") || !strings.Contains(out.String(), "FFF0:") {
+        t.Errorf("Expected rows from 0000 to FFF0")
+    }
+}
+
+func TestFormatFlags(t *testing.T) {
+    if s := FormatFlags(core.FlagZ | core.FlagCY); s != "s Z ac p CY" {
+        t.Errorf("Expected \"s Z ac p CY\", got=%q", s)
+    }
+}
+
+func TestWatchpoints(t *testing.T) {
+    // MVI B,3F; LXI B,2010; STAX B; LDAX B; NOP
+    d := newTestDebugger([]uint8{0x06, 0x3f, 0x01, 0x10, 0x20, 0x02, 0x0a, 0x00})
+    out := &bytes.Buffer{}
+    d.out = out
+    d.AddWatchpoint(0x2010, 0x2010, memory.AccessWrite, false)
+    d.AddWatchpoint(0x2000, 0x20FF, memory.AccessRead, true)
+
+    _, n := d.Continue(100)
+    if n != 3 || d.CPU.PC != 0x0006 {
+        t.Fatalf("Expected write watch to stop after STAX, got=(n=%d PC=%04X)", n, d.CPU.PC)
+    }
+    hits := d.Hits()
+    if len(hits) != 1 || hits[0].PC != 0x0005 || hits[0].Addr != 0x2010 ||
+        hits[0].Access != memory.AccessWrite {
+        t.Errorf("Expected one write hit at PC=0005, got=%+v", hits)
+    }
+
+    // The read watch only logs, so this runs to the limit.
+    _, n = d.Continue(2)
+    if n != 2 {
+        t.Errorf("Expected logging watchpoint not to stop, got=%d", n)
+    }
+    if !strings.Contains(out.String(), "watch 2: read 2010 = 00 at PC=0006") {
+        t.Errorf("Expected read to be logged, got=%s", out.String())
+    }
+}
+
+// A machine that counts in a loop while its interrupt handlers count too,
+// so any instruction replayed differently shows up in the registers or RAM.
+func newTestMachine() *Debugger {
+    rom := make([]uint8, memory.Kilobytes(8))
+    // LXI SP,2400; EI; JMP 0020
+    copy(rom, []uint8{0x31, 0x00, 0x24, 0xfb, 0xc3, 0x20, 0x00})
+    // RST 1: INR D; EI; RET
+    copy(rom[0x08:], []uint8{0x14, 0xfb, 0xc9})
+    // RST 2: INR E; EI; RET
+    copy(rom[0x10:], []uint8{0x1c, 0xfb, 0xc9})
+    // INR C; LXI H,2000; INR M; JMP 0020
+    copy(rom[0x20:], []uint8{0x0c, 0x21, 0x00, 0x20, 0x34, 0xc3, 0x20, 0x00})
+
+    return NewMachine(machine.New(rom))
+}
+
+func TestReverseStep(t *testing.T) {
+    d := newTestMachine()
+    out := &bytes.Buffer{}
+    d.out = out
+
+    // Record the state at every instruction across a few checkpoints.
+    const n = 3*CheckpointEvery + 500
+    states := make([]machine.State, n+1)
+    for i := 0; i < n; i++ {
+        d.Machine.SaveState(&states[i])
+        d.Step(1)
+    }
+    d.Machine.SaveState(&states[n])
+    if d.CPU.D == 0 || d.CPU.E == 0 {
+        t.Fatalf("Expected interrupts to be taken, got=(D=%d E=%d)", d.CPU.D, d.CPU.E)
+    }
+
+    var got machine.State
+    for _, back := range []int{1, 7, CheckpointEvery, CheckpointEvery + 3} {
+        before := d.Count()
+        if b, err := d.ReverseStep(back); err != nil || b != back {
+            t.Fatalf("Expected to go back %d, got=(%d, %v)", back, b, err)
+        }
+        d.Machine.SaveState(&got)
+        if d.Count() != before-uint64(back) || got != states[d.Count()] {
+            t.Errorf("Expected state of instruction %d after going back %d", before-uint64(back), back)
+        }
+    }
+
+    // Running forward again has to retrace the recorded run exactly.
+    d.Step(int(n - d.Count()))
+    d.Machine.SaveState(&got)
+    if got != states[n] {
+        t.Errorf("Expected replay to reach the same state")
+    }
+    if strings.Contains(out.String(), "diverged") {
+        t.Errorf("Expected no divergence, got=%s", out.String())
+    }
+
+    if b, err := d.ReverseStep(n + 10); err != nil || b != n {
+        t.Errorf("Expected to stop at the start after %d, got=(%d, %v)", n, b, err)
+    }
+    if _, err := d.ReverseStep(1); err != ErrNoHistory {
+        t.Errorf("Expected ErrNoHistory, got=%v", err)
+    }
+}
+
+func TestReverseContinue(t *testing.T) {
+    d := newTestMachine()
+    d.SetBreakpoint(0x0008, nil)
+
+    // Note where the RST 1 handler was last entered.
+    var last uint64
+    for i := 0; i < 2*CheckpointEvery+100; i++ {
+        if d.CPU.PC == 0x0008 {
+            last = d.Count()
+        }
+        d.Step(1)
+    }
+    if last == 0 {
+        t.Fatal("Expected the handler to run")
+    }
+
+    bp, _, err := d.ReverseContinue()
+    if err != nil || bp == nil || bp.Addr != 0x0008 || d.Count() != last {
+        t.Fatalf("Expected breakpoint 0008 at %d, got=(%v, %d, %v)", last, bp, d.Count(), err)
+    }
+
+    // Without breakpoints it goes all the way back.
+    d.RemoveBreakpoint(0x0008)
+    if _, _, err := d.ReverseContinue(); err != ErrNoHistory || d.Count() != 0 || d.CPU.PC != 0 {
+        t.Errorf("Expected the start of history, got=(%v, %d, PC=%04X)", err, d.Count(), d.CPU.PC)
+    }
+
+    if _, _, err := newTestDebugger(nil).ReverseContinue(); err != ErrNoMachine {
+        t.Errorf("Expected ErrNoMachine, got=%v", err)
+    }
+}
+
+// Changing the machine behind the debugger's back breaks determinism, which
+// has to be caught rather than replayed wrongly.
+func TestDivergence(t *testing.T) {
+    d := newTestMachine()
+    out := &bytes.Buffer{}
+    d.out = out
+    d.Step(CheckpointEvery + 10)
+    d.ReverseStep(20)
+    d.Machine.Mem.Ram[0x100] = 0xff
+    d.Step(20)
+    if !strings.Contains(out.String(), "diverged") {
+        t.Errorf("Expected a divergence warning, got=%s", out.String())
+    }
+
+    // Patching the ROM area behind its back is caught the same way.
+    out.Reset()
+    d.ReverseStep(20)
+    d.Machine.Mem.Rom[0x1fff] ^= 0xff
+    d.Step(20)
+    if !strings.Contains(out.String(), "diverged") {
+        t.Errorf("Expected a divergence warning for a ROM change, got=%s", out.String())
+    }
+
+    // Changes made through the debugger are fine.
+    out.Reset()
+    d.ReverseStep(20)
+    if err := d.Exec("w 2100 ff"); err != nil {
+        t.Fatal(err)
+    }
+    d.Step(20)
+    d.ReverseStep(20)
+    d.Step(20)
+    if strings.Contains(out.String(), "diverged") {
+        t.Errorf("Expected no divergence, got=%s", out.String())
+    }
+}
+
+// Writes to the ROM area have to be undone by going back past them.
+func TestReverseROMWrite(t *testing.T) {
+    d := newTestMachine()
+    var before machine.State
+    d.Step(100)
+    d.Machine.SaveState(&before)
+    d.Step(100)
+    // LXI H,2000 becomes LXI H,2100.
+    if err := d.Exec("w 0023 21"); err != nil {
+        t.Fatal(err)
+    }
+    d.Step(100)
+
+    if _, err := d.ReverseStep(50); err != nil || d.Machine.Mem.Rom[0x23] != 0x21 {
+        t.Fatalf("Expected the patch to stay after the write, got=(%02X, %v)", d.Machine.Mem.Rom[0x23], err)
+    }
+    if _, err := d.ReverseStep(150); err != nil {
+        t.Fatal(err)
+    }
+    var got machine.State
+    d.Machine.SaveState(&got)
+    if d.Machine.Mem.Rom[0x23] != 0x20 || got != before {
+        t.Errorf("Expected the ROM and state from before the write, got=%02X", d.Machine.Mem.Rom[0x23])
+    }
+    if n := len(d.checkpoints); n != 2 || d.checkpoints[1].state.ROM[0x23] != 0x21 {
+        t.Errorf("Expected a checkpoint with the patched ROM after the write, got=%d", n)
+    }
+}
@@ -0,0 +1,207 @@
+package debugger
+
+import (
+    "errors"
+    "fmt"
+    "sort"
+
+    "github.com/siathema/goInvadeSpace/machine"
+)
+
+// Going backwards works by restoring the nearest checkpoint before the
+// target and running forward to it again. That only lands in the same
+// place because the machine is deterministic: the same state and inputs
+// always give the same next state. Every replay past a checkpoint checks
+// that against what was recorded the first time round.
+const (
+    // Instructions between checkpoints, a replay runs at most this many.
+    CheckpointEvery = 20000
+    // Checkpoints kept, about 16K each with RAM and the ROM area. The
+    // oldest are dropped past it.
+    MaxCheckpoints = 500
+)
+
+var (
+    ErrNoMachine = errors.New("reverse execution needs a machine, see NewMachine")
+    ErrNoHistory = errors.New("at the start of history")
+    // ErrDiverged means running forward from a checkpoint didn't reproduce
+    // the recorded run, the history past that point was discarded.
+    ErrDiverged = errors.New("execution diverged from history")
+)
+
+type checkpoint struct {
+    count uint64
+    state machine.State
+}
+
+// NewMachine debugs a whole machine: interrupts are raised as they come due
+// and execution can be reversed.
+func NewMachine(m *machine.Machine) *Debugger {
+    d := New(m.CPU, m.Mem)
+    d.Machine = m
+    d.rebase()
+
+    return d
+}
+
+// Count is the number of instructions executed since the debugger started,
+// less any stepped back over.
+func (d *Debugger) Count() uint64 {
+    return d.count
+}
+
+// ReverseStep goes back n instructions, or as far as the history reaches,
+// and returns how many it went back.
+func (d *Debugger) ReverseStep(n int) (int, error) {
+    if d.Machine == nil {
+        return 0, ErrNoMachine
+    }
+    oldest := d.checkpoints[0].count
+    if d.count == oldest {
+        return 0, ErrNoHistory
+    }
+    target := oldest
+    if d.count-oldest > uint64(n) {
+        target = d.count - uint64(n)
+    }
+    start := d.count
+    if err := d.seek(target); err != nil {
+        return 0, err
+    }
+
+    return int(start - d.count), nil
+}
+
+// ReverseContinue goes back to the last place Continue would have stopped
+// at a breakpoint before now. Watchpoints don't stop it. Without such a
+// place it stops at the start of history and returns ErrNoHistory.
+func (d *Debugger) ReverseContinue() (*Breakpoint, int, error) {
+    if d.Machine == nil {
+        return nil, 0, ErrNoMachine
+    }
+    start := d.count
+    if start == d.checkpoints[0].count {
+        return nil, 0, ErrNoHistory
+    }
+    // Search a checkpoint's worth of history at a time, newest first.
+    end := start
+    for k := d.before(start - 1); k >= 0; k-- {
+        from := d.checkpoints[k].count
+        if err := d.seek(from); err != nil {
+            return nil, 0, err
+        }
+        found, at := false, uint64(0)
+        for d.count < end {
+            if d.breakHere() != nil {
+                found, at = true, d.count
+            }
+            if err := d.replayTo(d.count + 1); err != nil {
+                return nil, 0, err
+            }
+        }
+        if found {
+            if err := d.seek(at); err != nil {
+                return nil, 0, err
+            }
+            bp := d.AtBreakpoint()
+            return bp, int(start - at), nil
+        }
+        end = from
+    }
+    if err := d.seek(end); err != nil {
+        return nil, 0, err
+    }
+
+    return nil, int(start - d.count), ErrNoHistory
+}
+
+// breakHere is AtBreakpoint without counting a hit.
+func (d *Debugger) breakHere() *Breakpoint {
+    bp, ok := d.breakpoints[d.CPU.PC]
+    if !ok || (bp.Cond != nil && !bp.Cond.Eval(d.CPU)) {
+        return nil
+    }
+
+    return bp
+}
+
+// seek restores the nearest checkpoint at or before target and runs
+// forward to it.
+func (d *Debugger) seek(target uint64) error {
+    cp := d.checkpoints[d.before(target)]
+    d.Machine.LoadState(&cp.state)
+    d.count = cp.count
+
+    return d.replayTo(target)
+}
+
+// replayTo runs the machine to instruction target without the debugger's
+// hooks, so watchpoints stay quiet.
+func (d *Debugger) replayTo(target uint64) error {
+    for d.count < target {
+        if err := d.checkpoint(); err != nil {
+            return err
+        }
+        d.Machine.Step()
+        d.count++
+    }
+
+    return nil
+}
+
+// checkpoint is called before each instruction. Every CheckpointEvery
+// instructions it records the state, or checks it against the recording
+// when this point has been passed before.
+func (d *Debugger) checkpoint() error {
+    if d.Machine == nil || d.count%CheckpointEvery != 0 {
+        return nil
+    }
+    i := d.before(d.count)
+    if i >= 0 && d.checkpoints[i].count == d.count {
+        d.Machine.SaveState(&d.scratch)
+        cp := d.checkpoints[i]
+        if d.scratch != cp.state {
+            d.rebase()
+            return fmt.Errorf("%w at instruction %d", ErrDiverged, d.count)
+        }
+        return nil
+    }
+    d.insert(i+1, d.count)
+
+    return nil
+}
+
+// rebase makes the current state the only future: the checkpoints from here
+// on are dropped and one is taken now. Called when the state is changed by
+// hand, since replaying from an earlier checkpoint wouldn't repeat that.
+func (d *Debugger) rebase() {
+    if d.Machine == nil {
+        return
+    }
+    i := d.before(d.count)
+    if i >= 0 && d.checkpoints[i].count == d.count {
+        i--
+    }
+    d.checkpoints = d.checkpoints[:i+1]
+    d.insert(i+1, d.count)
+}
+
+// before is the index of the last checkpoint at or before count, -1 when
+// there is none.
+func (d *Debugger) before(count uint64) int {
+    return sort.Search(len(d.checkpoints), func(i int) bool {
+        return d.checkpoints[i].count > count
+    }) - 1
+}
+
+func (d *Debugger) insert(i int, count uint64) {
+    cp := &checkpoint{count: count}
+    d.Machine.SaveState(&cp.state)
+    d.checkpoints = append(d.checkpoints, nil)
+    copy(d.checkpoints[i+1:], d.checkpoints[i:])
+    d.checkpoints[i] = cp
+    if len(d.checkpoints) > MaxCheckpoints {
+        d.checkpoints[0] = nil
+        d.checkpoints = d.checkpoints[1:]
+    }
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "strconv"
+
+    "github.com/siathema/goInvadeSpace/core"
+    "github.com/siathema/goInvadeSpace/symbols"
+)
+
+func disasmCmd(args []string) error {
+    fs := newFlagSet("disasm", "[START [END]]")
+    rf := addROMFlags(fs)
+    symFile := fs.String("syms", "", "extra symbol file")
+    if err := parse(fs, args); err != nil {
+        return err
+    }
+    if fs.NArg() > 2 {
+        return usageError("disasm takes at most START and END")
+    }
+
+    game, rom, err := rf.load()
+    if err != nil {
+        return err
+    }
+    syms := game.Syms()
+    if *symFile != "" {
+        t, err := symbols.Load(*symFile)
+        if err != nil {
+            return err
+        }
+        syms.Merge(t)
+    }
+
+    // By default all of the ROM, the first 8K and whatever is past it at
+    // 4000.
+    ranges := [][2]int{{0, len(rom)}}
+    if len(rom) > 0x2000 {
+        ranges = [][2]int{{0, 0x2000}, {0x4000, 0x4000 + len(rom) - 0x2000}}
+    }
+    if fs.NArg() > 0 {
+        start, err := parseAddr(syms, fs.Arg(0))
+        if err != nil {
+            return err
+        }
+        end := 0x10000
+        if fs.NArg() == 2 {
+            if end, err = parseAddr(syms, fs.Arg(1)); err != nil {
+                return err
+            }
+        }
+        if end <= start {
+            return usageError(fmt.Sprintf("END %04X is not after START %04X", end, start))
+        }
+        ranges = [][2]int{{start, end}}
+    }
+
+    m := game.New(rom)
+    out := bufio.NewWriter(os.Stdout)
+    for _, r := range ranges {
+        for addr := r[0]; addr < r[1]; {
+            a := uint16(addr)
+            opcode := []uint8{m.Mem.Read(a), m.Mem.Read(a + 1), m.Mem.Read(a + 2)}
+            if name, ok := syms.Symbol(a); ok {
+                fmt.Fprintf(out, "%s:\n", name)
+            }
+            text, size := core.DisassembleSym(opcode, syms)
+            raw := ""
+            for i := 0; i < size; i++ {
+                raw += fmt.Sprintf("%02X ", opcode[i])
+            }
+            fmt.Fprintf(out, "    %04X  %-9s %s\n", a, raw, text)
+            addr += size
+        }
+    }
+
+    return out.Flush()
+}
+
+// parseAddr takes hex or a symbol name.
+func parseAddr(syms *symbols.Table, s string) (int, error) {
+    if addr, ok := syms.Addr(s); ok {
+        return int(addr), nil
+    }
+    v, err := strconv.ParseUint(s, 16, 16)
+    if err != nil {
+        return 0, usageError(fmt.Sprintf("bad address %q", s))
+    }
+
+    return int(v), nil
+}
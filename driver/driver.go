@@ -0,0 +1,100 @@
+// Package driver lists the games that run on this hardware. Midway and
+// Taito reused the Space Invaders board for a run of games that differ in
+// their ROMs, how much of them there is and how the controls are wired, and
+// a Driver says all of that for one game.
+package driver
+
+import (
+    "fmt"
+    "sort"
+
+    "github.com/siathema/goInvadeSpace/machine"
+    "github.com/siathema/goInvadeSpace/romset"
+    "github.com/siathema/goInvadeSpace/symbols"
+)
+
+type Driver struct {
+    // Name is MAME's short name, which picks the game on the command line.
+    Name  string
+    Title string
+    Year  int
+    Maker string
+    // ROMs are the chips and where they sit in the ROM image. The first 8K
+    // of the image is mapped at 0000 and the rest from 4000, which is the
+    // whole memory map short of the RAM at 2000 every game shares. Every
+    // chip needs the checksums of a known good dump, so only games whose
+    // dumps have been checked are registered.
+    ROMs *romset.Set
+    // Inputs wires the controls, machine.InvadersInputs when nil.
+    Inputs *machine.InputLayout
+    // Symbols, when set, names the game's routines and variables for the
+    // debugger, traces and disassembly.
+    Symbols func() *symbols.Table
+    // Setup, when set, sets the dip switches of a new machine to the
+    // game's defaults.
+    Setup func(m *machine.Machine)
+}
+
+var drivers = map[string]*Driver{}
+
+// Register adds a game. It panics when the name is already taken, like
+// registering a second database/sql driver under a name does.
+func Register(d *Driver) {
+    if _, dup := drivers[d.Name]; dup {
+        panic("driver: Register called twice for " + d.Name)
+    }
+    drivers[d.Name] = d
+}
+
+func Lookup(name string) (*Driver, error) {
+    d, ok := drivers[name]
+    if !ok {
+        return nil, fmt.Errorf("driver: unknown game %q, known are %v", name, Names())
+    }
+
+    return d, nil
+}
+
+// Names lists the registered games in order.
+func Names() []string {
+    names := make([]string, 0, len(drivers))
+    for name := range drivers {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    return names
+}
+
+// New builds a machine for the game around a ROM image.
+func (d *Driver) New(rom []uint8) *machine.Machine {
+    m := machine.New(rom)
+    if d.Inputs != nil {
+        m.Ports.Layout = d.Inputs
+    }
+    if d.Setup != nil {
+        d.Setup(m)
+    }
+
+    return m
+}
+
+// Load reads and checks the game's ROMs from path, see romset.Set.Load,
+// and builds a machine around them.
+func (d *Driver) Load(path string) (*machine.Machine, error) {
+    rom, err := d.ROMs.Load(path)
+    if err != nil {
+        return nil, err
+    }
+
+    return d.New(rom), nil
+}
+
+// Syms returns a fresh copy of the game's symbols, empty when it has none.
+func (d *Driver) Syms() *symbols.Table {
+    if d.Symbols == nil {
+        return symbols.New()
+    }
+
+    return d.Symbols()
+}
@@ -0,0 +1,77 @@
+package driver
+
+import (
+    "strings"
+    "testing"
+
+    "github.com/siathema/goInvadeSpace/machine"
+    "github.com/siathema/goInvadeSpace/romset"
+)
+
+// A made up game with ROM past 8K and active low controls.
+var testGame = &Driver{
+    Name: "testgame",
+    ROMs: &romset.Set{Name: "testgame", Size: 0x2800},
+    Inputs: &machine.InputLayout{
+        Idle: [3]uint8{0xff, 0x00, 0x00},
+        Bits: []machine.InputBit{
+            {Input: machine.Coin, Port: 0, Mask: 0x01},
+            {Input: machine.P1Fire, Port: 0, Mask: 0x10},
+        },
+    },
+    Setup: func(m *machine.Machine) { m.Ports.Ships = 5 },
+}
+
+func init() {
+    Register(testGame)
+}
+
+func TestDriver(t *testing.T) {
+    d, err := Lookup("testgame")
+    if err != nil {
+        t.Fatal(err)
+    }
+    rom := make([]uint8, 0x2800)
+    rom[0x0000], rom[0x1fff], rom[0x2000], rom[0x27ff] = 1, 2, 3, 4
+    m := d.New(rom)
+
+    for _, tt := range []struct {
+        addr uint16
+        want uint8
+    }{{0x0000, 1}, {0x1fff, 2}, {0x4000, 3}, {0x47ff, 4}, {0x4800, 0}} {
+        if got := m.Mem.Read(tt.addr); got != tt.want {
+            t.Errorf("Expected %02X at %04X, got=%02X", tt.want, tt.addr, got)
+        }
+    }
+
+    m.Ports.Input = machine.Coin
+    if got := m.Ports.In(0); got != 0xfe {
+        t.Errorf("Expected coin to pull bit 0 low, got=%02X", got)
+    }
+    if m.Ports.Ships != 5 {
+        t.Errorf("Expected Setup to set the dip switches, got=%d ships", m.Ports.Ships)
+    }
+
+    if _, err := Lookup("nosuchgame"); err == nil || !strings.Contains(err.Error(), "invaders") {
+        t.Errorf("Expected an error listing the known games, got=%v", err)
+    }
+    defer func() {
+        if recover() == nil {
+            t.Errorf("Expected registering a name twice to panic")
+        }
+    }()
+    Register(&Driver{Name: "invaders"})
+}
+
+func TestInvaders(t *testing.T) {
+    m, err := Invaders.Load("../roms")
+    if err != nil {
+        t.Skip("no ROM: ", err)
+    }
+    for i := 0; i < 200; i++ {
+        m.RunFrame()
+    }
+    if m.Ports.Layout != &machine.InvadersInputs || m.CPU.PC >= 0x2000 {
+        t.Errorf("Expected the game running with its own wiring, got PC=%04X", m.CPU.PC)
+    }
+}
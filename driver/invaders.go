@@ -0,0 +1,23 @@
+package driver
+
+import (
+    "github.com/siathema/goInvadeSpace/machine"
+    "github.com/siathema/goInvadeSpace/romset"
+    "github.com/siathema/goInvadeSpace/symbols"
+)
+
+// Invaders is the original, with 8K of ROM, the wiring and dip switches
+// machine.Ports describes.
+var Invaders = &Driver{
+    Name:    "invaders",
+    Title:   "Space Invaders",
+    Year:    1978,
+    Maker:   "Taito / Midway",
+    ROMs:    romset.Invaders,
+    Inputs:  &machine.InvadersInputs,
+    Symbols: symbols.Invaders,
+}
+
+func init() {
+    Register(Invaders)
+}
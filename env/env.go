@@ -0,0 +1,223 @@
+package env
+
+import (
+    "errors"
+    "math/rand"
+
+    "github.com/siathema/goInvadeSpace/gamestate"
+    "github.com/siathema/goInvadeSpace/machine"
+)
+
+// Action is what the agent does for one step, the same minimal set and
+// order the Arcade Learning Environment uses for this game.
+type Action int
+
+const (
+    Noop Action = iota
+    Fire
+    Right
+    Left
+    RightFire
+    LeftFire
+    NumActions
+)
+
+var actionInputs = [NumActions]machine.Input{
+    Noop:      0,
+    Fire:      machine.P1Fire,
+    Right:     machine.P1Right,
+    Left:      machine.P1Left,
+    RightFire: machine.P1Right | machine.P1Fire,
+    LeftFire:  machine.P1Left | machine.P1Fire,
+}
+
+var actionNames = [NumActions]string{"NOOP", "FIRE", "RIGHT", "LEFT", "RIGHTFIRE", "LEFTFIRE"}
+
+func (a Action) String() string {
+    if a < 0 || a >= NumActions {
+        return "?"
+    }
+    return actionNames[a]
+}
+
+type ObsType int
+
+const (
+    // The screen, downsampled by Config.Downsample, one byte per pixel,
+    // 0 or 255, row by row from the top left.
+    ObsScreen ObsType = iota
+    // The 1K of work RAM at 2000-23FF.
+    ObsRAM
+)
+
+type Config struct {
+    ROM []uint8
+    Obs ObsType
+    // Screen pixels per observation pixel along each axis, 1 or more.
+    Downsample int
+    // Frames each action is held for.
+    FrameSkip int
+    // Chance the previous action is repeated for a frame instead of the
+    // new one.
+    StickyProb float64
+    // Up to this many idle frames, picked by the seed, after the game
+    // starts, so episodes with different seeds diverge.
+    NoopMax int
+    // Ends the episode after this many frames, 0 for no limit.
+    MaxFrames int
+}
+
+func DefaultConfig(rom []uint8) Config {
+    return Config{ROM: rom, Obs: ObsScreen, Downsample: 2, FrameSkip: 4, StickyProb: 0.25, NoopMax: 30}
+}
+
+// Frames Reset holds player 1 start for before giving up on the game.
+const startFrames = 300
+
+// ErrNoGame is returned by Reset when the game doesn't start, e.g. a bad
+// dump or a ROM that isn't Space Invaders.
+var ErrNoGame = errors.New("env: the game didn't start")
+
+// Env plays single player games on a fresh machine per episode.
+type Env struct {
+    cfg    Config
+    m      *machine.Machine
+    rng    *rand.Rand
+    last   Action
+    score  int
+    frames int
+    obs    []uint8
+}
+
+func New(cfg Config) *Env {
+    if cfg.Downsample < 1 {
+        cfg.Downsample = 1
+    }
+    if cfg.FrameSkip < 1 {
+        cfg.FrameSkip = 1
+    }
+    e := &Env{cfg: cfg}
+    e.obs = make([]uint8, e.ObsSize())
+
+    return e
+}
+
+// ObsSize is the length of every observation.
+func (e *Env) ObsSize() int {
+    if e.cfg.Obs == ObsRAM {
+        return 0x400
+    }
+    w, h := e.ObsShape()
+
+    return w * h
+}
+
+// ObsShape is the width and height of screen observations.
+func (e *Env) ObsShape() (int, int) {
+    return machine.ScreenWidth / e.cfg.Downsample, machine.ScreenHeight / e.cfg.Downsample
+}
+
+// Machine is the machine of the current episode.
+func (e *Env) Machine() *machine.Machine {
+    return e.m
+}
+
+// Reset starts a new episode: a fresh machine, a coin, player 1 start and
+// the seed's worth of idle frames. It returns the first observation, which
+// like every later one is reused by the next call.
+func (e *Env) Reset(seed int64) ([]uint8, error) {
+    e.m = machine.New(e.cfg.ROM)
+    e.rng = rand.New(rand.NewSource(seed))
+    e.last = Noop
+    e.frames = 0
+
+    e.hold(0, 60)
+    e.hold(machine.Coin, 5)
+    e.hold(0, 30)
+    for i := 0; !e.State().InGame; i++ {
+        if i == startFrames {
+            return nil, ErrNoGame
+        }
+        e.hold(machine.P1Start, 1)
+    }
+    if e.cfg.NoopMax > 0 {
+        e.hold(0, e.rng.Intn(e.cfg.NoopMax+1))
+    }
+    e.score = e.State().Score[0]
+
+    return e.observe(), nil
+}
+
+// Step plays action for FrameSkip frames and returns the observation, the
+// points scored meanwhile and whether the episode is over.
+func (e *Env) Step(action Action) ([]uint8, float64, bool) {
+    for i := 0; i < e.cfg.FrameSkip; i++ {
+        if e.cfg.StickyProb == 0 || e.rng.Float64() >= e.cfg.StickyProb {
+            e.last = action
+        }
+        e.m.Ports.Input = actionInputs[e.last]
+        e.m.RunFrame()
+        e.frames++
+        if e.done() {
+            break
+        }
+    }
+    score := e.State().Score[0]
+    reward := float64(score - e.score)
+    e.score = score
+
+    return e.observe(), reward, e.done()
+}
+
+// Score is player 1's score.
+func (e *Env) Score() int {
+    return e.score
+}
+
+func (e *Env) done() bool {
+    if e.cfg.MaxFrames > 0 && e.frames >= e.cfg.MaxFrames {
+        return true
+    }
+
+    return !e.State().InGame
+}
+
+func (e *Env) hold(in machine.Input, frames int) {
+    e.m.Ports.Input = in
+    for i := 0; i < frames; i++ {
+        e.m.RunFrame()
+    }
+    e.m.Ports.Input = 0
+}
+
+// State decodes the game's RAM.
+func (e *Env) State() gamestate.State {
+    return gamestate.Read(e.m.Mem.Ram)
+}
+
+func (e *Env) observe() []uint8 {
+    if e.cfg.Obs == ObsRAM {
+        copy(e.obs, e.m.Mem.Ram[:0x400])
+        return e.obs
+    }
+    vram := e.m.VRAM()
+    k := e.cfg.Downsample
+    w, h := e.ObsShape()
+    for oy := 0; oy < h; oy++ {
+        for ox := 0; ox < w; ox++ {
+            // A block is lit if any of its pixels is.
+            v := uint8(0)
+            for y := oy * k; y < oy*k+k && v == 0; y++ {
+                for x := ox * k; x < ox*k+k; x++ {
+                    if machine.Pixel(vram, x, y) {
+                        v = 0xff
+                        break
+                    }
+                }
+            }
+            e.obs[oy*w+ox] = v
+        }
+    }
+
+    return e.obs
+}
@@ -0,0 +1,103 @@
+package env
+
+import (
+    "bytes"
+    "fmt"
+    "math/rand"
+    "os"
+    "testing"
+)
+
+func loadROM(t *testing.T) []uint8 {
+    rom, err := os.ReadFile("../roms/invaders.rom")
+    if err != nil {
+        t.Skip("no ROM: ", err)
+    }
+    return rom
+}
+
+// play runs one episode with actions drawn from rng and returns the total
+// reward, the number of steps and the lit pixel count of every observation.
+func play(t *testing.T, e *Env, seed int64, maxSteps int) (float64, int, []int) {
+    obs, err := e.Reset(seed)
+    if err != nil {
+        t.Fatal(err)
+    }
+    trail := []int{bytes.Count(obs, []uint8{0xff})}
+    rng := rand.New(rand.NewSource(seed))
+    total := 0.0
+    for step := 1; step <= maxSteps; step++ {
+        obs, reward, done := e.Step(Action(rng.Intn(int(NumActions))))
+        total += reward
+        trail = append(trail, bytes.Count(obs, []uint8{0xff}))
+        if done {
+            return total, step, trail
+        }
+    }
+    return total, maxSteps, trail
+}
+
+func TestObservation(t *testing.T) {
+    e := New(DefaultConfig(loadROM(t)))
+    obs, err := e.Reset(1)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if w, h := e.ObsShape(); len(obs) != w*h || w != 112 || h != 128 {
+        t.Fatalf("Expected a 112x128 observation, got %dx%d and %d bytes", w, h, len(obs))
+    }
+    if bytes.Count(obs, []uint8{0xff}) == 0 {
+        t.Errorf("Expected something on screen after the game starts")
+    }
+
+    cfg := DefaultConfig(loadROM(t))
+    cfg.Obs = ObsRAM
+    e = New(cfg)
+    // 20EF is gameMode.
+    if obs, err := e.Reset(1); err != nil || len(obs) != 0x400 || obs[0xef] != 1 {
+        t.Errorf("Expected RAM with the game running, got %d bytes and %v", len(obs), err)
+    }
+}
+
+func TestNoGame(t *testing.T) {
+    // All NOPs, the game flag never comes up.
+    e := New(DefaultConfig(make([]uint8, 0x2000)))
+    if _, err := e.Reset(1); err != ErrNoGame {
+        t.Errorf("Expected ErrNoGame, got=%v", err)
+    }
+}
+
+func TestEpisode(t *testing.T) {
+    e := New(DefaultConfig(loadROM(t)))
+    total, steps, _ := play(t, e, 7, 20000)
+    if steps == 20000 {
+        t.Fatalf("Expected the game to end within 20000 steps")
+    }
+    if total <= 0 || int(total) != e.Score() {
+        t.Errorf("Expected the rewards to add up to the score %d, got=%v", e.Score(), total)
+    }
+}
+
+func TestDeterminism(t *testing.T) {
+    rom := loadROM(t)
+    a, b := New(DefaultConfig(rom)), New(DefaultConfig(rom))
+    ra, sa, ta := play(t, a, 3, 500)
+    rb, sb, tb := play(t, b, 3, 500)
+    if ra != rb || sa != sb || fmt.Sprint(ta) != fmt.Sprint(tb) {
+        t.Errorf("Expected the same seed to play the same episode")
+    }
+    _, _, tc := play(t, b, 4, 500)
+    if fmt.Sprint(ta) == fmt.Sprint(tc) {
+        t.Errorf("Expected another seed to play differently")
+    }
+}
+
+func TestMaxFrames(t *testing.T) {
+    cfg := DefaultConfig(loadROM(t))
+    cfg.MaxFrames = 40
+    e := New(cfg)
+    _, steps, _ := play(t, e, 1, 100)
+    if steps != 10 {
+        t.Errorf("Expected 40 frames to take 10 steps, got=%d", steps)
+    }
+}
@@ -0,0 +1,146 @@
+package gamestate
+
+// RAM addresses, see symbols/invaders.sym for the names the debugger uses.
+const (
+    ramBase = 0x2000
+
+    playerAlive   = 0x2015
+    playerX       = 0x201b
+    plyrShotStat  = 0x2025
+    plyrShotY     = 0x2029
+    plyrShotX     = 0x202a
+    rolShot       = 0x2030
+    pluShot       = 0x2040
+    squShot       = 0x2050
+    playerDataMSB = 0x2067
+    numAliens     = 0x2082
+    saucerActive  = 0x2084
+    saucerHit     = 0x2085
+    // 2087 and 2088 point at the saucer's picture in ROM, its position
+    // follows them.
+    saucerY       = 0x2089
+    saucerX       = 0x208a
+    refAlienY     = 0x2009
+    refAlienX     = 0x200a
+    numCoins      = 0x20eb
+    gameMode      = 0x20ef
+    hiScore       = 0x20f4
+    p1Score       = 0x20f8
+    p2Score       = 0x20fc
+    p1ShipsRem    = 0x21ff
+    p2ShipsRem    = 0x22ff
+
+    // The alien shots share a layout, these are offsets into each.
+    shotStatus = 0x05
+    shotY      = 0x0d
+    shotX      = 0x0e
+)
+
+const (
+    AlienRows = 5
+    AlienCols = 11
+)
+
+// Positions are in the game's own coordinates, see ScreenPos.
+type Shot struct {
+    Active bool
+    X, Y   uint8
+}
+
+type Saucer struct {
+    Active bool
+    Hit    bool
+    X, Y   uint8
+}
+
+// State is what the game keeps in RAM, decoded.
+type State struct {
+    Credits int
+    // A game is being played, as opposed to the attract mode.
+    InGame bool
+    // The player whose turn it is, 1 or 2.
+    Player  int
+    Score   [2]int
+    HiScore int
+    // Ships in reserve for each player. The one in play isn't counted, so
+    // the number on screen is one more for the player who's up.
+    Lives [2]int
+
+    PlayerX     uint8
+    PlayerAlive bool
+    PlayerShot  Shot
+    // The rolling, plunger and squiggly shots.
+    AlienShots [3]Shot
+    Saucer     Saucer
+
+    // The current player's rack, row 0 at the bottom, column 0 at the
+    // left. The reference alien is the bottom left one, alive or not.
+    Aliens     [AlienRows][AlienCols]bool
+    AliensLeft int
+    RefAlienX  uint8
+    RefAlienY  uint8
+}
+
+// Read decodes ram, the 8K from 2000 up as memory.MainMemory.Ram holds it.
+func Read(ram []uint8) State {
+    at := func(addr uint16) uint8 { return ram[addr-ramBase] }
+    s := State{
+        Credits:     bcd(at(numCoins)),
+        InGame:      at(gameMode) != 0,
+        Player:      1,
+        Score:       [2]int{score(ram, p1Score), score(ram, p2Score)},
+        HiScore:     score(ram, hiScore),
+        Lives:       [2]int{int(at(p1ShipsRem)), int(at(p2ShipsRem))},
+        PlayerX:     at(playerX),
+        PlayerAlive: at(playerAlive) == 0xff,
+        PlayerShot: Shot{
+            Active: at(plyrShotStat) != 0,
+            X:      at(plyrShotX),
+            Y:      at(plyrShotY),
+        },
+        Saucer: Saucer{
+            Active: at(saucerActive) != 0,
+            Hit:    at(saucerHit) != 0,
+            X:      at(saucerX),
+            Y:      at(saucerY),
+        },
+        AliensLeft: int(at(numAliens)),
+        RefAlienX:  at(refAlienX),
+        RefAlienY:  at(refAlienY),
+    }
+    for i, base := range []uint16{rolShot, pluShot, squShot} {
+        s.AlienShots[i] = Shot{
+            Active: at(base+shotStatus)&0x80 != 0,
+            X:      at(base + shotX),
+            Y:      at(base + shotY),
+        }
+    }
+    table := uint16(0x2100)
+    if at(playerDataMSB) == 0x22 {
+        s.Player = 2
+        table = 0x2200
+    }
+    for row := 0; row < AlienRows; row++ {
+        for col := 0; col < AlienCols; col++ {
+            s.Aliens[row][col] = at(table+uint16(row*AlienCols+col)) != 0
+        }
+    }
+
+    return s
+}
+
+// ScreenPos converts game coordinates to a pixel of the upright screen,
+// 0, 0 top left. The game counts Y up from the bottom and X from the start
+// of RAM, 32 columns left of the screen.
+func ScreenPos(x, y uint8) (int, int) {
+    return int(x) - 32, 255 - int(y)
+}
+
+// Scores are 4 BCD digits, low byte first.
+func score(ram []uint8, addr uint16) int {
+    return bcd(ram[addr+1-ramBase])*100 + bcd(ram[addr-ramBase])
+}
+
+func bcd(b uint8) int {
+    return int(b>>4)*10 + int(b&0x0f)
+}
@@ -0,0 +1,109 @@
+package gamestate
+
+import (
+    "os"
+    "testing"
+)
+
+func loadRAM(t *testing.T, name string) []uint8 {
+    ram, err := os.ReadFile("testdata/" + name)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return ram
+}
+
+func TestDecode(t *testing.T) {
+    ram := make([]uint8, 0x2000)
+    ram[numCoins-ramBase] = 0x12
+    ram[gameMode-ramBase] = 1
+    ram[p1Score-ramBase], ram[p1Score+1-ramBase] = 0x50, 0x13
+    ram[p2Score-ramBase], ram[p2Score+1-ramBase] = 0x90, 0x00
+    ram[hiScore-ramBase], ram[hiScore+1-ramBase] = 0x70, 0x99
+    ram[playerDataMSB-ramBase] = 0x22
+    ram[p2ShipsRem-ramBase] = 4
+    ram[0x2200+AlienCols+3-ramBase] = 1
+    ram[pluShot+shotStatus-ramBase] = 0x80
+    ram[pluShot+shotX-ramBase] = 0x44
+
+    s := Read(ram)
+    if s.Credits != 12 || !s.InGame || s.Player != 2 {
+        t.Errorf("Expected 12 credits and player 2 in game, got=%+v", s)
+    }
+    if s.Score != [2]int{1350, 90} || s.HiScore != 9970 {
+        t.Errorf("Expected scores 1350, 90 and 9970, got=%v %d", s.Score, s.HiScore)
+    }
+    if s.Lives[1] != 4 || !s.Aliens[1][3] || s.Aliens[0][3] {
+        t.Errorf("Expected player 2's lives and rack, got=%v %v", s.Lives, s.Aliens)
+    }
+    if !s.AlienShots[1].Active || s.AlienShots[1].X != 0x44 || s.AlienShots[0].Active {
+        t.Errorf("Expected only the plunger shot, got=%+v", s.AlienShots)
+    }
+}
+
+// credits.ram is the attract mode after two coins. game.ram is a one
+// player game after moving right to x 96 and shooting the third alien of
+// the bottom row for 10 points.
+func TestSnapshots(t *testing.T) {
+    s := Read(loadRAM(t, "credits.ram"))
+    if s.Credits != 2 || s.InGame {
+        t.Errorf("Expected 2 credits in the attract mode, got=%+v", s)
+    }
+
+    s = Read(loadRAM(t, "game.ram"))
+    if s.Credits != 1 || !s.InGame || s.Player != 1 {
+        t.Errorf("Expected player 1 in game with a credit left, got=%+v", s)
+    }
+    if s.Score != [2]int{10, 0} || s.Lives[0] != 2 {
+        t.Errorf("Expected 10 points and 2 ships in reserve, got=%v %v", s.Score, s.Lives)
+    }
+    if s.PlayerX != 96 || !s.PlayerAlive {
+        t.Errorf("Expected the player alive at 96, got=%d %v", s.PlayerX, s.PlayerAlive)
+    }
+    if s.AliensLeft != 54 || s.Aliens[0][2] {
+        t.Errorf("Expected the third alien of the bottom row gone, got=%d left", s.AliensLeft)
+    }
+    alive := 0
+    for _, row := range s.Aliens {
+        for _, a := range row {
+            if a {
+                alive++
+            }
+        }
+    }
+    if alive != 54 {
+        t.Errorf("Expected 54 aliens in the table, got=%d", alive)
+    }
+    if x, y := ScreenPos(s.RefAlienX, s.RefAlienY); x != 30 || y != 135 {
+        t.Errorf("Expected the reference alien at 30,135, got=%d,%d", x, y)
+    }
+}
+
+// saucer.ram is a one player game with the saucer crossing the top of the
+// screen. Its timer at 2091 was cut short by hand to get there sooner.
+func TestSaucerSnapshot(t *testing.T) {
+    ram := loadRAM(t, "saucer.ram")
+    s := Read(ram)
+    if !s.InGame || !s.Saucer.Active || s.Saucer.Hit {
+        t.Fatalf("Expected the saucer flying in a game, got=%+v", s.Saucer)
+    }
+    if s.Saucer.X != 0x2f || s.Saucer.Y != 0xd0 {
+        t.Errorf("Expected the saucer at 2F,D0, got=%02X,%02X", s.Saucer.X, s.Saucer.Y)
+    }
+
+    // The sprite, 24 columns of 8 pixels, has to be in video memory where
+    // X and Y say. Y is a multiple of 8, so each column is one byte.
+    sprite := []uint8{
+        0x00, 0x00, 0x00, 0x00, 0x04, 0x0c, 0x1e, 0x37, 0x3e, 0x7c, 0x74, 0x7e,
+        0x7e, 0x74, 0x7c, 0x3e, 0x37, 0x1e, 0x0c, 0x04, 0x00, 0x00, 0x00, 0x00,
+    }
+    vram := ram[0x400:]
+    for i, col := range sprite {
+        if got := vram[(int(s.Saucer.X)-32+i)*32+int(s.Saucer.Y)/8]; got != col {
+            t.Fatalf("Expected sprite column %d to be %02X, got=%02X", i, col, got)
+        }
+    }
+    if x, y := ScreenPos(s.Saucer.X, s.Saucer.Y); x != 15 || y != 47 {
+        t.Errorf("Expected the saucer at 15,47 on screen, got=%d,%d", x, y)
+    }
+}
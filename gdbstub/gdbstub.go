@@ -0,0 +1,531 @@
+// Package gdbstub lets an external debugger drive a Core8080 over the GDB
+// remote serial protocol.
+//
+// GDB has no 8080 target, so registers are laid out like its z80 target
+// (AF BC DE HL SP PC IX IY AF' BC' DE' HL' IR, 16 bits little endian each)
+// with the z80-only registers reading as zero. "set architecture z80" is
+// enough to attach a stock gdb-multiarch.
+package gdbstub
+
+import (
+    "bufio"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "strconv"
+    "strings"
+    "sync/atomic"
+
+    "github.com/siathema/goInvadeSpace/core"
+    "github.com/siathema/goInvadeSpace/debugger"
+    "github.com/siathema/goInvadeSpace/machine"
+    "github.com/siathema/goInvadeSpace/memory"
+)
+
+const numRegs = 13
+
+// Instructions run between checks for a ^C or the client hanging up.
+const runChunk = 10000
+
+type packet struct {
+    data string
+    ok   bool
+}
+
+type watchKey struct {
+    kind   byte
+    lo, hi uint16
+}
+
+// Server exposes one CPU and its memory, or a whole machine, to one client
+// at a time. Breakpoints and watchpoints are kept in a debugger.Debugger so
+// both front ends behave the same way.
+type Server struct {
+    CPU *core.Core8080
+    Mem memory.Memory
+
+    dbg       *debugger.Debugger
+    watches   map[watchKey]int
+    w         *bufio.Writer
+    packets   chan packet
+    // hangup is closed when the client goes away, which c has to notice
+    // since it isn't reading packets while it runs.
+    hangup    chan struct{}
+    interrupt atomic.Bool
+    noAck     bool
+}
+
+// New serves a bare CPU and memory, with nothing raising interrupts.
+func New(cpu *core.Core8080, mem memory.Memory) *Server {
+    return newServer(debugger.New(cpu, mem))
+}
+
+// NewMachine serves a whole machine, whose video timing raises RST 1 and
+// RST 2 as the instructions run.
+func NewMachine(m *machine.Machine) *Server {
+    return newServer(debugger.NewMachine(m))
+}
+
+func newServer(dbg *debugger.Debugger) *Server {
+    return &Server{
+        CPU:     dbg.CPU,
+        Mem:     dbg.Mem,
+        dbg:     dbg,
+        watches: make(map[watchKey]int),
+    }
+}
+
+// ListenAndServe serves clients connecting to addr, e.g. "localhost:1234".
+func (s *Server) ListenAndServe(addr string) error {
+    l, err := net.Listen("tcp", addr)
+    if err != nil {
+        return err
+    }
+    defer l.Close()
+
+    return s.Serve(l)
+}
+
+// Serve accepts clients from l one after the other. The CPU state carries
+// over between sessions.
+func (s *Server) Serve(l net.Listener) error {
+    for {
+        conn, err := l.Accept()
+        if err != nil {
+            return err
+        }
+        err = s.ServeConn(conn)
+        conn.Close()
+        if err != nil && !errors.Is(err, io.EOF) {
+            return err
+        }
+    }
+}
+
+// ServeConn runs a session until the client detaches, kills or hangs up.
+func (s *Server) ServeConn(conn io.ReadWriter) error {
+    s.w = bufio.NewWriter(conn)
+    s.packets = make(chan packet)
+    s.hangup = make(chan struct{})
+    s.noAck = false
+    done := make(chan struct{})
+    defer close(done)
+    go s.readPackets(bufio.NewReader(conn), done)
+
+    for pkt := range s.packets {
+        if !s.noAck {
+            // Flush the ack straight away, c can take a while to reply.
+            ack := byte('+')
+            if !pkt.ok {
+                ack = '-'
+            }
+            s.w.WriteByte(ack)
+            if err := s.w.Flush(); err != nil {
+                return err
+            }
+            if !pkt.ok {
+                continue
+            }
+        }
+        reply, quit := s.handle(pkt.data)
+        if err := s.send(reply); err != nil {
+            return err
+        }
+        if quit {
+            return nil
+        }
+    }
+
+    return io.EOF
+}
+
+// readPackets splits the byte stream into packets. A bare 0x03 is the
+// client asking a running target to stop and is flagged straight away.
+func (s *Server) readPackets(r *bufio.Reader, done chan struct{}) {
+    defer close(s.packets)
+    defer close(s.hangup)
+    for {
+        b, err := r.ReadByte()
+        if err != nil {
+            return
+        }
+        switch b {
+        case 0x03:
+            s.interrupt.Store(true)
+            continue
+        case '$':
+        default:
+            // '+' and '-' acks, nothing gets resent over TCP anyway
+            continue
+        }
+        data, err := r.ReadString('#')
+        if err != nil {
+            return
+        }
+        data = data[:len(data)-1]
+        sum := make([]byte, 2)
+        if _, err := io.ReadFull(r, sum); err != nil {
+            return
+        }
+        want, err := strconv.ParseUint(string(sum), 16, 8)
+        pkt := packet{data: unescape(data), ok: err == nil && uint8(want) == checksum(data)}
+        select {
+        case s.packets <- pkt:
+        case <-done:
+            return
+        }
+    }
+}
+
+func checksum(data string) uint8 {
+    var sum uint8
+    for i := 0; i < len(data); i++ {
+        sum += data[i]
+    }
+
+    return sum
+}
+
+func unescape(data string) string {
+    if !strings.Contains(data, "}") {
+        return data
+    }
+    out := make([]byte, 0, len(data))
+    for i := 0; i < len(data); i++ {
+        if data[i] == '}' && i+1 < len(data) {
+            i++
+            out = append(out, data[i]^0x20)
+        } else {
+            out = append(out, data[i])
+        }
+    }
+
+    return string(out)
+}
+
+func (s *Server) send(data string) error {
+    fmt.Fprintf(s.w, "$%s#%02x", data, checksum(data))
+
+    return s.w.Flush()
+}
+
+// handle returns the reply to one packet and whether the session is over.
+func (s *Server) handle(data string) (string, bool) {
+    if data == "" {
+        return "", false
+    }
+    cmd, args := data[0], data[1:]
+
+    switch cmd {
+    case '?':
+        return "S05", false
+    case 'g':
+        return s.readRegs(), false
+    case 'G':
+        return s.writeRegs(args), false
+    case 'p':
+        n, err := strconv.ParseUint(args, 16, 8)
+        if err != nil || n >= numRegs {
+            return "E01", false
+        }
+        return encodeReg(s.reg(int(n))), false
+    case 'P':
+        return s.writeReg(args), false
+    case 'm':
+        return s.readMem(args), false
+    case 'M':
+        return s.writeMem(args), false
+    case 's':
+        if err := s.jumpTo(args); err != nil {
+            return "E01", false
+        }
+        return s.step(), false
+    case 'c':
+        if err := s.jumpTo(args); err != nil {
+            return "E01", false
+        }
+        return s.cont(), false
+    case 'Z', 'z':
+        return s.breakpoint(cmd == 'Z', args), false
+    case 'H':
+        return "OK", false
+    case 'D':
+        return "OK", true
+    case 'k':
+        return "", true
+    case 'q':
+        switch {
+        case strings.HasPrefix(args, "Supported"):
+            return "PacketSize=1000;QStartNoAckMode+", false
+        case args == "Attached":
+            return "1", false
+        case args == "C":
+            return "QC1", false
+        case args == "fThreadInfo":
+            return "m1", false
+        case args == "sThreadInfo":
+            return "l", false
+        }
+    case 'Q':
+        if args == "StartNoAckMode" {
+            // The OK still gets acked by the client, stop expecting it after.
+            defer func() { s.noAck = true }()
+            return "OK", false
+        }
+    }
+
+    // Empty reply means unsupported.
+    return "", false
+}
+
+func (s *Server) reg(n int) uint16 {
+    c := s.CPU
+    switch n {
+    case 0:
+        return uint16(c.A)<<8 | uint16(c.Flags)
+    case 1:
+        return uint16(c.B)<<8 | uint16(c.C)
+    case 2:
+        return uint16(c.D)<<8 | uint16(c.E)
+    case 3:
+        return uint16(c.H)<<8 | uint16(c.L)
+    case 4:
+        return c.SP
+    case 5:
+        return c.PC
+    }
+
+    return 0
+}
+
+func (s *Server) setReg(n int, v uint16) {
+    c := s.CPU
+    hi, lo := uint8(v>>8), uint8(v)
+    switch n {
+    case 0:
+        c.A, c.Flags = hi, lo
+    case 1:
+        c.B, c.C = hi, lo
+    case 2:
+        c.D, c.E = hi, lo
+    case 3:
+        c.H, c.L = hi, lo
+    case 4:
+        c.SP = v
+    case 5:
+        c.PC = v
+    }
+}
+
+func encodeReg(v uint16) string {
+    return fmt.Sprintf("%02x%02x", uint8(v), uint8(v>>8))
+}
+
+func decodeReg(s string) (uint16, error) {
+    b, err := hex.DecodeString(s)
+    if err != nil || len(b) != 2 {
+        return 0, errors.New("bad register value")
+    }
+
+    return uint16(b[1])<<8 | uint16(b[0]), nil
+}
+
+func (s *Server) readRegs() string {
+    var sb strings.Builder
+    for n := 0; n < numRegs; n++ {
+        sb.WriteString(encodeReg(s.reg(n)))
+    }
+
+    return sb.String()
+}
+
+func (s *Server) writeRegs(args string) string {
+    if len(args) != numRegs*4 {
+        return "E01"
+    }
+    for n := 0; n < numRegs; n++ {
+        v, err := decodeReg(args[n*4 : n*4+4])
+        if err != nil {
+            return "E01"
+        }
+        s.setReg(n, v)
+    }
+
+    return "OK"
+}
+
+func (s *Server) writeReg(args string) string {
+    num, value, found := strings.Cut(args, "=")
+    n, err := strconv.ParseUint(num, 16, 8)
+    if !found || err != nil || n >= numRegs {
+        return "E01"
+    }
+    v, err := decodeReg(value)
+    if err != nil {
+        return "E01"
+    }
+    s.setReg(int(n), v)
+
+    return "OK"
+}
+
+// "ADDR,LEN" as sent by m, M and Z packets
+func parseAddrLen(s string) (uint16, int, error) {
+    a, l, found := strings.Cut(s, ",")
+    if !found {
+        return 0, 0, errors.New("missing length")
+    }
+    addr, err := strconv.ParseUint(a, 16, 16)
+    if err != nil {
+        return 0, 0, err
+    }
+    length, err := strconv.ParseUint(l, 16, 16)
+    if err != nil {
+        return 0, 0, err
+    }
+
+    return uint16(addr), int(length), nil
+}
+
+func (s *Server) readMem(args string) string {
+    addr, length, err := parseAddrLen(args)
+    if err != nil {
+        return "E01"
+    }
+    buf := make([]byte, length)
+    for i := range buf {
+        buf[i] = s.Mem.Read(addr + uint16(i))
+    }
+
+    return hex.EncodeToString(buf)
+}
+
+func (s *Server) writeMem(args string) string {
+    head, data, found := strings.Cut(args, ":")
+    addr, length, err := parseAddrLen(head)
+    if !found || err != nil {
+        return "E01"
+    }
+    buf, err := hex.DecodeString(data)
+    if err != nil || len(buf) != length {
+        return "E01"
+    }
+    for i, b := range buf {
+        if err := s.Mem.Write(addr+uint16(i), b); err != nil {
+            return "E02"
+        }
+    }
+
+    return "OK"
+}
+
+// s and c may carry an address to resume from.
+func (s *Server) jumpTo(args string) error {
+    if args == "" {
+        return nil
+    }
+    addr, err := strconv.ParseUint(args, 16, 16)
+    if err != nil {
+        return err
+    }
+    s.CPU.PC = uint16(addr)
+
+    return nil
+}
+
+func (s *Server) step() string {
+    s.dbg.Step(1)
+    if reply, ok := s.watchReply(); ok {
+        return reply
+    }
+
+    return "S05"
+}
+
+func (s *Server) cont() string {
+    s.interrupt.Store(false)
+    for first := true; ; first = false {
+        // Continue lets the first instruction through unchecked, which is
+        // only wanted when resuming, not at every chunk boundary.
+        if !first && s.dbg.AtBreakpoint() != nil {
+            return "S05"
+        }
+        bp, _ := s.dbg.Continue(runChunk)
+        if bp != nil {
+            return "S05"
+        }
+        if reply, ok := s.watchReply(); ok {
+            return reply
+        }
+        if s.interrupt.Load() {
+            return "S02"
+        }
+        select {
+        case <-s.hangup:
+            // Nobody to tell, ServeConn sees the end of the packets next.
+            return ""
+        default:
+        }
+    }
+}
+
+func (s *Server) watchReply() (string, bool) {
+    hits := s.dbg.Hits()
+    if len(hits) == 0 {
+        return "", false
+    }
+    h := hits[0]
+    kind := "watch"
+    if h.Watch.Access == memory.AccessRead {
+        kind = "rwatch"
+    } else if h.Watch.Access == memory.AccessReadWrite {
+        kind = "awatch"
+    }
+
+    return fmt.Sprintf("T05%s:%04x;", kind, h.Addr), true
+}
+
+// Z/z TYPE,ADDR,KIND. Types 0 and 1 are code breakpoints, 2, 3 and 4 are
+// write, read and access watchpoints with KIND as the length.
+func (s *Server) breakpoint(insert bool, args string) string {
+    if len(args) < 2 || args[1] != ',' {
+        return "E01"
+    }
+    kind := args[0]
+    addr, length, err := parseAddrLen(args[2:])
+    if err != nil {
+        return "E01"
+    }
+
+    switch kind {
+    case '0', '1':
+        if insert {
+            s.dbg.SetBreakpoint(addr, nil)
+        } else {
+            s.dbg.RemoveBreakpoint(addr)
+        }
+        return "OK"
+    case '2', '3', '4':
+        if length < 1 {
+            length = 1
+        }
+        key := watchKey{kind: kind, lo: addr, hi: addr + uint16(length-1)}
+        if !insert {
+            if id, ok := s.watches[key]; ok {
+                s.dbg.RemoveWatchpoint(id)
+                delete(s.watches, key)
+            }
+            return "OK"
+        }
+        access := map[byte]memory.Access{
+            '2': memory.AccessWrite, '3': memory.AccessRead, '4': memory.AccessReadWrite,
+        }[kind]
+        wp := s.dbg.AddWatchpoint(key.lo, key.hi, access, false)
+        s.watches[key] = wp.ID
+        return "OK"
+    }
+
+    return ""
+}
@@ -0,0 +1,200 @@
+package gdbstub
+
+import (
+    "bufio"
+    "fmt"
+    "net"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/siathema/goInvadeSpace/core"
+    "github.com/siathema/goInvadeSpace/machine"
+    "github.com/siathema/goInvadeSpace/memory"
+)
+
+type testClient struct {
+    t    *testing.T
+    addr string
+    conn net.Conn
+    r    *bufio.Reader
+}
+
+func (c *testClient) send(data string) {
+    fmt.Fprintf(c.conn, "$%s#%02x", data, checksum(data))
+}
+
+// call sends a packet and returns the reply, checking the acks on the way.
+func (c *testClient) call(data string) string {
+    c.t.Helper()
+    c.send(data)
+    c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+    if ack, err := c.r.ReadByte(); err != nil || ack != '+' {
+        c.t.Fatalf("Expected ack for %q, got=(%q, %v)", data, ack, err)
+    }
+    if b, err := c.r.ReadByte(); err != nil || b != '$' {
+        c.t.Fatalf("Expected reply for %q, got=(%q, %v)", data, b, err)
+    }
+    reply, err := c.r.ReadString('#')
+    if err != nil {
+        c.t.Fatal(err)
+    }
+    reply = reply[:len(reply)-1]
+    sum := make([]byte, 2)
+    c.r.Read(sum)
+    if string(sum) != fmt.Sprintf("%02x", checksum(reply)) {
+        c.t.Errorf("Bad checksum on %q", reply)
+    }
+    c.conn.Write([]byte{'+'})
+
+    return reply
+}
+
+func startServer(t *testing.T, program []uint8) (*Server, *testClient) {
+    rom := make([]uint8, memory.Kilobytes(8))
+    copy(rom, program)
+
+    return serveTest(t, New(core.New(), memory.NewMainMemory(rom)))
+}
+
+func serveTest(t *testing.T, s *Server) (*Server, *testClient) {
+    l, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Skip("no loopback networking:", err)
+    }
+    t.Cleanup(func() { l.Close() })
+    go s.Serve(l)
+
+    conn, err := net.Dial("tcp", l.Addr().String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { conn.Close() })
+
+    return s, &testClient{t: t, addr: l.Addr().String(), conn: conn, r: bufio.NewReader(conn)}
+}
+
+func TestRegistersAndMemory(t *testing.T) {
+    s, c := startServer(t, []uint8{0x06, 0x12, 0x0e, 0x34})
+
+    if r := c.call("qSupported:swbreak+"); !strings.Contains(r, "PacketSize") {
+        t.Errorf("Expected PacketSize in qSupported, got=%q", r)
+    }
+    if r := c.call("?"); r != "S05" {
+        t.Errorf("Expected S05, got=%q", r)
+    }
+    if r := c.call("s"); r != "S05" {
+        t.Errorf("Expected S05 after step, got=%q", r)
+    }
+    c.call("s")
+
+    regs := c.call("g")
+    if len(regs) != numRegs*4 {
+        t.Fatalf("Expected %d hex digits, got=%q", numRegs*4, regs)
+    }
+    // BC is the second register, PC the sixth, both little endian.
+    if regs[4:8] != "3412" || regs[20:24] != "0400" {
+        t.Errorf("Expected BC=1234 PC=0004, got=%q", regs)
+    }
+
+    if r := c.call("P4=0024"); r != "OK" || s.CPU.SP != 0x2400 {
+        t.Errorf("Expected SP=2400, got=(%q, %04X)", r, s.CPU.SP)
+    }
+    if r := c.call("p4"); r != "0024" {
+        t.Errorf("Expected 0024, got=%q", r)
+    }
+
+    if r := c.call("M2000,3:deadbe"); r != "OK" {
+        t.Errorf("Expected OK, got=%q", r)
+    }
+    if r := c.call("m1fff,5"); r != "00deadbe00" {
+        t.Errorf("Expected 00deadbe00, got=%q", r)
+    }
+    if r := c.call("m0,2"); r != "0612" {
+        t.Errorf("Expected 0612, got=%q", r)
+    }
+    if r := c.call("vMustReplyEmpty"); r != "" {
+        t.Errorf("Expected empty reply, got=%q", r)
+    }
+}
+
+func TestBreakpointsAndWatchpoints(t *testing.T) {
+    // LXI B,2010; NOP; NOP; STAX B; NOP; JMP 0007
+    s, c := startServer(t, []uint8{
+        0x01, 0x10, 0x20, 0x00, 0x00, 0x02, 0x00, 0xc3, 0x07, 0x00,
+    })
+
+    if r := c.call("Z0,4,1"); r != "OK" {
+        t.Fatalf("Expected OK, got=%q", r)
+    }
+    if r := c.call("c"); r != "S05" || s.CPU.PC != 0x0004 {
+        t.Errorf("Expected stop at 0004, got=(%q, %04X)", r, s.CPU.PC)
+    }
+    c.call("z0,4,1")
+
+    if r := c.call("Z2,2010,1"); r != "OK" {
+        t.Fatalf("Expected OK, got=%q", r)
+    }
+    if r := c.call("c"); r != "T05watch:2010;" || s.CPU.PC != 0x0006 {
+        t.Errorf("Expected write watch at 2010, got=(%q, %04X)", r, s.CPU.PC)
+    }
+    c.call("z2,2010,1")
+}
+
+func TestInterrupt(t *testing.T) {
+    // JMP 0000 spins forever until the client sends ^C.
+    s, c := startServer(t, []uint8{0xc3, 0x00, 0x00})
+    s.CPU.PC = 0x0000
+
+    c.send("c")
+    if ack, _ := c.r.ReadByte(); ack != '+' {
+        t.Fatalf("Expected ack, got=%q", ack)
+    }
+    time.Sleep(10 * time.Millisecond)
+    c.conn.Write([]byte{0x03})
+    c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+    reply, err := c.r.ReadString('#')
+    if err != nil || reply != "$S02#" {
+        t.Errorf("Expected $S02#, got=(%q, %v)", reply, err)
+    }
+}
+
+func TestHangupWhileRunning(t *testing.T) {
+    // JMP 0000 never reaches a breakpoint.
+    _, c := startServer(t, []uint8{0xc3, 0x00, 0x00})
+
+    c.send("c")
+    if ack, _ := c.r.ReadByte(); ack != '+' {
+        t.Fatalf("Expected ack, got=%q", ack)
+    }
+    c.conn.Close()
+
+    // The server has to give up on c and take the next client.
+    conn, err := net.Dial("tcp", c.addr)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer conn.Close()
+    c2 := &testClient{t: t, addr: c.addr, conn: conn, r: bufio.NewReader(conn)}
+    if r := c2.call("?"); r != "S05" {
+        t.Errorf("Expected S05 from a new session, got=%q", r)
+    }
+}
+
+// Served whole, the machine's video timing interrupts the program as it
+// would running free.
+func TestMachineInterrupts(t *testing.T) {
+    rom := make([]uint8, memory.Kilobytes(8))
+    copy(rom, []uint8{0xfb, 0xc3, 0x01, 0x00}) // EI; JMP 0001
+    copy(rom[0x08:], []uint8{0xfb, 0xc9})      // RST 1: EI; RET
+    copy(rom[0x10:], []uint8{0xfb, 0xc9})      // RST 2: EI; RET
+    s, c := serveTest(t, NewMachine(machine.New(rom)))
+
+    for _, addr := range []uint16{0x0008, 0x0010} {
+        c.call(fmt.Sprintf("Z0,%x,1", addr))
+        if r := c.call("c"); r != "S05" || s.CPU.PC != addr {
+            t.Errorf("Expected the handler at %04X, got=(%q, %04X)", addr, r, s.CPU.PC)
+        }
+        c.call(fmt.Sprintf("z0,%x,1", addr))
+    }
+}
@@ -0,0 +1,100 @@
+package machine
+
+import (
+    "runtime"
+    "sync"
+)
+
+// Snapshot is a copy of a machine's RAM, video memory included, taken
+// after a frame.
+type Snapshot struct {
+    Frame uint64
+    RAM   []uint8
+}
+
+// VRAM is the part of RAM the screen is drawn from.
+func (s *Snapshot) VRAM() []uint8 {
+    return s.RAM[VRAMStart-0x2000:]
+}
+
+// Result is what one run of a batch collected.
+type Result struct {
+    Job       int
+    Snapshots []Snapshot
+}
+
+// Batch runs many machines at once, one per input sequence. Each sequence
+// holds the controls for one frame per entry and the run lasts as many
+// frames as the sequence is long.
+type Batch struct {
+    // ROM is only read, every machine works on its own copy.
+    ROM []uint8
+    // Goroutines to spread the runs over, runtime.NumCPU() when 0.
+    Workers int
+    // Take a snapshot every this many frames. The final frame is always
+    // kept, 0 keeps only that one.
+    SnapshotEvery int
+    // Setup, when set, is called on each fresh machine before it runs,
+    // e.g. to set dip switches.
+    Setup func(job int, m *Machine)
+    // Observe, when set, is called after every frame from the goroutine
+    // running the job.
+    Observe func(job int, m *Machine)
+}
+
+// Run plays every input sequence on its own machine and returns the
+// results in the order of inputs.
+func (b *Batch) Run(inputs [][]Input) []Result {
+    workers := b.Workers
+    if workers <= 0 {
+        workers = runtime.NumCPU()
+    }
+    results := make([]Result, len(inputs))
+    jobs := make(chan int)
+    var wg sync.WaitGroup
+    for w := 0; w < workers; w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for job := range jobs {
+                results[job] = b.run(job, inputs[job])
+            }
+        }()
+    }
+    for job := range inputs {
+        jobs <- job
+    }
+    close(jobs)
+    wg.Wait()
+
+    return results
+}
+
+func (b *Batch) run(job int, inputs []Input) Result {
+    m := New(b.ROM)
+    if b.Setup != nil {
+        b.Setup(job, m)
+    }
+    r := Result{Job: job}
+    for i, in := range inputs {
+        m.Ports.Input = in
+        m.RunFrame()
+        if b.Observe != nil {
+            b.Observe(job, m)
+        }
+        last := i == len(inputs)-1
+        if last || (b.SnapshotEvery > 0 && (i+1)%b.SnapshotEvery == 0) {
+            r.Snapshots = append(r.Snapshots, m.Snapshot())
+        }
+    }
+
+    return r
+}
+
+// Snapshot copies the machine's RAM.
+func (m *Machine) Snapshot() Snapshot {
+    ram := make([]uint8, len(m.Mem.Ram))
+    copy(ram, m.Mem.Ram)
+
+    return Snapshot{Frame: m.Frame, RAM: ram}
+}
@@ -0,0 +1,109 @@
+package machine
+
+import (
+    "github.com/siathema/goInvadeSpace/core"
+    "github.com/siathema/goInvadeSpace/memory"
+)
+
+const (
+    ClockHz        = 2000000
+    FrameRate      = 60
+    CyclesPerFrame = ClockHz / FrameRate
+
+    // Video RAM, one bit per pixel, 256 pixels a column from the bottom
+    // up, 224 columns from the left.
+    VRAMStart = 0x2400
+    VRAMSize  = 0x1c00
+
+    // ROMSize is the ROM at 0000. An image is at least this big, more
+    // goes from 4000 up.
+    ROMSize = 0x2000
+)
+
+// Machine is one Space Invaders board: a CPU, its memory and ports, and
+// the video timing that interrupts it. Machines share nothing, any number
+// of them can run side by side.
+type Machine struct {
+    CPU   *core.Core8080
+    Mem   *memory.MainMemory
+    Ports *Ports
+
+    // Frames completed so far.
+    Frame uint64
+
+    rom []uint8
+
+    // The video hardware raises RST 1 when the beam reaches the middle of
+    // the screen and RST 2 at vblank.
+    nextInt    uint64
+    nextVector uint8
+}
+
+// New builds a machine around a ROM image of at least ROMSize bytes. The
+// first 8K is mapped at 0000 and anything past it from 4000, as on the
+// boards with more ROM. The image
+// is copied: memory.MainMemory lets writes into the ROM area through, as
+// the debugger's w command relies on, and those mustn't reach the caller's
+// buffer, another machine or ROM().
+func New(rom []uint8) *Machine {
+    m := &Machine{
+        CPU:   core.New(),
+        Ports: NewPorts(),
+        rom:   append([]uint8(nil), rom...),
+    }
+    image := append([]uint8(nil), rom...)
+    if len(image) > ROMSize {
+        m.Mem = memory.NewMainMemory(image[:ROMSize])
+        m.Mem.ExtRom = image[ROMSize:]
+    } else {
+        m.Mem = memory.NewMainMemory(image)
+    }
+    m.CPU.IO = m.Ports
+    m.nextInt = CyclesPerFrame / 2
+    m.nextVector = 1
+
+    return m
+}
+
+// Step runs one instruction, then any interrupt that has come due.
+func (m *Machine) Step() {
+    m.CPU.RunTick(m.Mem)
+    m.Raise(m.Mem)
+}
+
+// Raise takes the interrupt that has come due, if any, pushing the return
+// address through mem. It's the second half of Step for callers that run
+// the CPU themselves against a view of Mem, like the debugger.
+func (m *Machine) Raise(mem memory.Memory) {
+    if m.CPU.Cycles() < m.nextInt {
+        return
+    }
+    m.CPU.Interrupt(m.nextVector, mem)
+    if m.nextVector == 2 {
+        m.Frame++
+        m.nextVector = 1
+        m.nextInt = m.Frame*CyclesPerFrame + CyclesPerFrame/2
+    } else {
+        m.nextVector = 2
+        m.nextInt = (m.Frame + 1) * CyclesPerFrame
+    }
+}
+
+// RunFrame runs up to and including the next vblank interrupt.
+func (m *Machine) RunFrame() {
+    frame := m.Frame
+    for m.Frame == frame {
+        m.Step()
+    }
+}
+
+// ROM is the image the machine was built with, whatever has been written
+// to the ROM area since. It must not be modified.
+func (m *Machine) ROM() []uint8 {
+    return m.rom
+}
+
+// VRAM returns the live video memory.
+func (m *Machine) VRAM() []uint8 {
+    return m.Mem.Ram[VRAMStart-0x2000:]
+}
@@ -0,0 +1,567 @@
+package machine
+
+import (
+    "bytes"
+    "crypto/sha1"
+    "crypto/sha256"
+    "encoding/binary"
+    "encoding/hex"
+    "hash/crc32"
+    "flag"
+    "fmt"
+    "os"
+    "strings"
+    "testing"
+)
+
+func loadROM(t *testing.T) []uint8 {
+    rom, err := os.ReadFile("../roms/invaders.rom")
+    if err != nil {
+        t.Skip("no ROM: ", err)
+    }
+    return rom
+}
+
+func TestShiftRegister(t *testing.T) {
+    p := NewPorts()
+    p.Out(4, 0xab)
+    p.Out(4, 0xcd)
+    tests := []struct {
+        offset   uint8
+        expected uint8
+    }{
+        {0, 0xcd},
+        {4, 0xda},
+        {7, 0xd5},
+    }
+
+    for _, tt := range tests {
+        p.Out(2, tt.offset)
+        if v := p.In(3); v != tt.expected {
+            t.Errorf("Expected offset %d to read %02X, got=%02X", tt.offset, tt.expected, v)
+        }
+    }
+}
+
+func TestInputPorts(t *testing.T) {
+    p := NewPorts()
+    if v := p.In(1); v != 0x08 {
+        t.Errorf("Expected idle port 1 to read 08, got=%02X", v)
+    }
+    p.Input = Coin | P1Start | P1Fire | P2Left
+    p.Ships = 5
+    if v := p.In(1); v != 0x1d {
+        t.Errorf("Expected port 1 to read 1D, got=%02X", v)
+    }
+    if v := p.In(2); v != 0x22 {
+        t.Errorf("Expected port 2 to read 22, got=%02X", v)
+    }
+}
+
+func runFrames(m *Machine, in Input, n int) {
+    m.Ports.Input = in
+    for i := 0; i < n; i++ {
+        m.RunFrame()
+    }
+    m.Ports.Input = 0
+}
+
+func TestBootAndStart(t *testing.T) {
+    m := New(loadROM(t))
+    runFrames(m, 0, 600)
+    if m.Frame != 600 {
+        t.Errorf("Expected 600 frames, got=%d", m.Frame)
+    }
+    if bytes.Count(m.VRAM(), []uint8{0}) == VRAMSize {
+        t.Errorf("Expected the attract mode to have drawn something")
+    }
+
+    runFrames(m, Coin, 5)
+    runFrames(m, 0, 30)
+    if coins := m.Mem.Read(0x20eb); coins != 0x01 {
+        t.Fatalf("Expected 1 credit, got=%02X", coins)
+    }
+    runFrames(m, P1Start, 5)
+    runFrames(m, 0, 120)
+    if mode := m.Mem.Read(0x20ef); mode != 0x01 {
+        t.Errorf("Expected game mode after start, got=%02X", mode)
+    }
+}
+
+func TestIndependentMachines(t *testing.T) {
+    rom := loadROM(t)
+    a, b, c := New(rom), New(rom), New(rom)
+    runFrames(a, 0, 300)
+    if b.Frame != 0 || b.CPU.Cycles() != 0 {
+        t.Fatalf("Expected running one machine to leave another alone")
+    }
+    runFrames(b, 0, 300)
+    runFrames(c, 0, 100)
+    runFrames(c, Coin, 5)
+    runFrames(c, 0, 195)
+
+    if !bytes.Equal(a.Mem.Ram, b.Mem.Ram) || a.CPU.Cycles() != b.CPU.Cycles() {
+        t.Errorf("Expected machines fed the same input to end in the same state")
+    }
+    if bytes.Equal(a.Mem.Ram, c.Mem.Ram) {
+        t.Errorf("Expected a coin to change the machine state")
+    }
+}
+
+func TestROMCopied(t *testing.T) {
+    rom := loadROM(t)
+    orig := append([]uint8(nil), rom...)
+    m := New(rom)
+    m.Mem.Write(0x0000, ^rom[0])
+    if m.Mem.Read(0x0000) != ^orig[0] {
+        t.Fatalf("Expected the write to reach the machine's ROM area")
+    }
+    if !bytes.Equal(rom, orig) || !bytes.Equal(m.ROM(), orig) {
+        t.Errorf("Expected the caller's image and ROM() to be left alone")
+    }
+    if New(rom).Mem.Read(0x0000) != orig[0] {
+        t.Errorf("Expected a new machine to see the original ROM")
+    }
+}
+
+func TestBatch(t *testing.T) {
+    rom := loadROM(t)
+    var inputs [][]Input
+    for job := 0; job < 8; job++ {
+        seq := make([]Input, 240)
+        // Coin at a different frame for every job.
+        for i := 0; i < 5; i++ {
+            seq[60+job*10+i] = Coin
+        }
+        inputs = append(inputs, seq)
+    }
+
+    b := &Batch{ROM: rom, Workers: 4, SnapshotEvery: 60}
+    results := b.Run(inputs)
+    if len(results) != len(inputs) {
+        t.Fatalf("Expected %d results, got=%d", len(inputs), len(results))
+    }
+    for job, r := range results {
+        if r.Job != job || len(r.Snapshots) != 4 {
+            t.Fatalf("Expected job %d with 4 snapshots, got job %d with %d",
+                job, r.Job, len(r.Snapshots))
+        }
+        if f := r.Snapshots[3].Frame; f != 240 {
+            t.Errorf("Expected the last snapshot at frame 240, got=%d", f)
+        }
+
+        // Each run must match the same inputs played on their own.
+        m := New(rom)
+        for _, in := range inputs[job] {
+            runFrames(m, in, 1)
+        }
+        if !bytes.Equal(r.Snapshots[3].RAM, m.Mem.Ram) {
+            t.Errorf("Job %d: batch RAM differs from a serial run", job)
+        }
+    }
+}
+
+// Machines patching their ROM area mustn't see each other's patches, or
+// race on them under go test -race.
+func TestBatchROMWrites(t *testing.T) {
+    rom := loadROM(t)
+    orig := append([]uint8(nil), rom...)
+    b := &Batch{
+        ROM:     rom,
+        Workers: 4,
+        Setup: func(job int, m *Machine) {
+            m.Mem.Write(0x1fff, uint8(job))
+        },
+        Observe: func(job int, m *Machine) {
+            if got := m.Mem.Read(0x1fff); got != uint8(job) {
+                t.Errorf("Job %d: expected its own patch, got=%02X", job, got)
+            }
+            m.Mem.Write(0x1fff, uint8(job))
+        },
+    }
+    inputs := make([][]Input, 8)
+    for i := range inputs {
+        inputs[i] = make([]Input, 10)
+    }
+    b.Run(inputs)
+    if !bytes.Equal(rom, orig) {
+        t.Errorf("Expected the batch's ROM to be left alone")
+    }
+}
+
+func BenchmarkBatch(b *testing.B) {
+    rom, err := os.ReadFile("../roms/invaders.rom")
+    if err != nil {
+        b.Skip("no ROM: ", err)
+    }
+    inputs := make([][]Input, 64)
+    for i := range inputs {
+        inputs[i] = make([]Input, 60)
+    }
+    batch := &Batch{ROM: rom}
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        batch.Run(inputs)
+    }
+    b.ReportMetric(float64(b.N*64*60)/b.Elapsed().Seconds(), "frames/s")
+}
+
+func TestPixel(t *testing.T) {
+    vram := make([]uint8, VRAMSize)
+    // The first byte is the bottom of the leftmost column, bit 0 lowest.
+    vram[0] = 0x01
+    vram[VRAMSize-1] = 0x80
+    if !Pixel(vram, 0, ScreenHeight-1) || !Pixel(vram, ScreenWidth-1, 0) {
+        t.Errorf("Expected the corner pixels to be lit")
+    }
+    if Pixel(vram, 0, 0) || Pixel(vram, 1, ScreenHeight-1) {
+        t.Errorf("Expected other pixels to be dark")
+    }
+}
+
+func TestSaveLoad(t *testing.T) {
+    rom := loadROM(t)
+    a := New(rom)
+    runFrames(a, 0, 100)
+    runFrames(a, Coin, 5)
+    runFrames(a, 0, 60)
+    runFrames(a, P1Start, 5)
+    runFrames(a, 0, 200)
+    // Mid-frame, with a control held down.
+    a.Ports.Input = P1Left | P1Fire
+    for i := 0; i < 1234; i++ {
+        a.Step()
+    }
+
+    var buf bytes.Buffer
+    if err := a.Save(&buf); err != nil {
+        t.Fatal(err)
+    }
+    saved := buf.Bytes()
+
+    b := New(rom)
+    if err := b.Load(bytes.NewReader(saved)); err != nil {
+        t.Fatal(err)
+    }
+    for i := 0; i < 100; i++ {
+        a.RunFrame()
+        b.RunFrame()
+    }
+    var sa, sb State
+    a.SaveState(&sa)
+    b.SaveState(&sb)
+    if sa != sb {
+        t.Errorf("Expected a restored machine to run exactly like the original")
+    }
+
+    corrupt := append([]byte(nil), saved...)
+    corrupt[len(corrupt)-100] ^= 0x01
+    if err := b.Load(bytes.NewReader(corrupt)); err != ErrChecksum {
+        t.Errorf("Expected ErrChecksum, got=%v", err)
+    }
+    newer := append([]byte(nil), saved...)
+    newer[8] = stateVersion + 1
+    if err := b.Load(bytes.NewReader(newer)); err != ErrVersion {
+        t.Errorf("Expected ErrVersion, got=%v", err)
+    }
+    if err := b.Load(bytes.NewReader([]byte("not a state"))); err != ErrNotState {
+        t.Errorf("Expected ErrNotState, got=%v", err)
+    }
+    other := append([]uint8(nil), rom...)
+    other[0x1fff] ^= 0xff
+    if err := New(other).Load(bytes.NewReader(saved)); err != ErrROMChanged {
+        t.Errorf("Expected ErrROMChanged, got=%v", err)
+    }
+
+    b.SaveState(&sb)
+    if sa != sb {
+        t.Errorf("Expected failed loads to leave the machine alone")
+    }
+}
+
+func TestStateMigration(t *testing.T) {
+    m := New(loadROM(t))
+    runFrames(m, 0, 10)
+    var buf bytes.Buffer
+    if err := m.Save(&buf); err != nil {
+        t.Fatal(err)
+    }
+    old := buf.Bytes()
+    old[8] = 0
+    if err := m.Load(bytes.NewReader(old)); err == nil {
+        t.Fatalf("Expected an old version without a migration to fail")
+    }
+
+    // Version 1 is version 2 without the ROM area and Layout at the end.
+    var want State
+    m.SaveState(&want)
+    payload := make([]byte, StateSize)
+    want.encode(payload)
+    v1 := append([]byte(nil), old[:headerSize]...)
+    v1 = append(v1, payload[:stateSizeV1]...)
+    binary.LittleEndian.PutUint16(v1[8:], 1)
+    binary.LittleEndian.PutUint32(v1[16:], stateSizeV1)
+    binary.LittleEndian.PutUint32(v1[20:], crc32.ChecksumIEEE(payload[:stateSizeV1]))
+    b := New(loadROM(t))
+    if err := b.Load(bytes.NewReader(v1)); err != nil {
+        t.Fatalf("Expected a version 1 state to load, got=%v", err)
+    }
+    var got State
+    b.SaveState(&got)
+    if got != want {
+        t.Errorf("Expected the migrated state to match")
+    }
+}
+
+// Writes to the ROM area and the input wiring are part of a state.
+func TestStateROMAndLayout(t *testing.T) {
+    rom := loadROM(t)
+    a := New(rom)
+    runFrames(a, 0, 10)
+    a.Mem.Write(0x0023, 0x21)
+    var buf bytes.Buffer
+    if err := a.Save(&buf); err != nil {
+        t.Fatal(err)
+    }
+
+    b := New(rom)
+    if err := b.Load(bytes.NewReader(buf.Bytes())); err != nil {
+        t.Fatal(err)
+    }
+    if b.Mem.Read(0x0023) != 0x21 || b.ROM()[0x23] == 0x21 {
+        t.Errorf("Expected the write to the ROM area restored, ROM() left alone")
+    }
+
+    c := New(rom)
+    c.Ports.Layout = &InputLayout{Idle: [3]uint8{0xff, 0xff, 0xff}}
+    if err := c.Load(bytes.NewReader(buf.Bytes())); err != ErrLayout {
+        t.Errorf("Expected ErrLayout, got=%v", err)
+    }
+}
+
+func TestStateEncoding(t *testing.T) {
+    m := New(loadROM(t))
+    runFrames(m, P1Fire, 50)
+    var s State
+    m.SaveState(&s)
+    var expected bytes.Buffer
+    binary.Write(&expected, binary.LittleEndian, &s)
+    buf := make([]byte, StateSize)
+    s.encode(buf)
+    if !bytes.Equal(buf, expected.Bytes()) {
+        t.Fatalf("Expected encode to match binary.Write")
+    }
+    var back State
+    back.decode(buf)
+    if back != s {
+        t.Errorf("Expected decode to undo encode")
+    }
+}
+
+func TestRewind(t *testing.T) {
+    m := New(loadROM(t))
+    runFrames(m, 0, 100)
+    runFrames(m, Coin, 5)
+    runFrames(m, 0, 60)
+    runFrames(m, P1Start, 5)
+
+    r := NewRewind(4, 1<<20)
+    var states []State
+    for i := 0; i < 400; i++ {
+        m.Ports.Input = []Input{P1Left, P1Fire, P1Right, 0}[i/20%4]
+        m.RunFrame()
+        if m.Frame%4 == 0 {
+            var s State
+            m.SaveState(&s)
+            states = append(states, s)
+        }
+        r.Capture(m)
+    }
+    if r.Len() != len(states) || r.Frames() != (len(states)-1)*4 {
+        t.Fatalf("Expected %d snapshots, got=%d", len(states), r.Len())
+    }
+    if r.Size() > len(states)*StateSize/4 {
+        t.Errorf("Expected deltas to be small, got %d bytes for %d snapshots", r.Size(), r.Len())
+    }
+
+    for i := len(states) - 1; i >= 0; i-- {
+        if !r.Back(m) {
+            t.Fatalf("Expected snapshot %d to be there", i)
+        }
+        var s State
+        m.SaveState(&s)
+        if s != states[i] {
+            t.Fatalf("Snapshot %d didn't come back exactly", i)
+        }
+    }
+    if r.Back(m) || r.Len() != 0 || r.Size() != 0 {
+        t.Errorf("Expected the trail to be used up")
+    }
+}
+
+func TestRewindBudget(t *testing.T) {
+    m := New(loadROM(t))
+    r := NewRewind(1, 4096)
+    for i := 0; i < 300; i++ {
+        m.RunFrame()
+        r.Capture(m)
+        if r.Size() > r.Budget {
+            t.Fatalf("Expected the trail to stay within %d bytes, got=%d", r.Budget, r.Size())
+        }
+    }
+    if r.Len() >= 300 {
+        t.Errorf("Expected old snapshots to be dropped, got=%d", r.Len())
+    }
+    n := 0
+    for r.Back(m) {
+        n++
+    }
+    if n == 0 || m.Frame != uint64(300-n+1) {
+        t.Errorf("Expected to rewind %d frames back to frame %d, got frame %d", n, 300-n+1, m.Frame)
+    }
+}
+
+func TestMovie(t *testing.T) {
+    rom := loadROM(t)
+    m := New(rom)
+    m.Ports.Ships = 5
+
+    // One movie from power on, a second picking up from the middle of it.
+    mv, err := RecordMovie(m)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var mid *Movie
+    inputs := []Input{0, Coin, 0, P1Start, 0, P1Fire | P1Left, P1Right, P1Fire}
+    for i := 0; i < 1200; i++ {
+        if i == 600 {
+            if mid, err = RecordMovie(m); err != nil {
+                t.Fatal(err)
+            }
+        }
+        m.Ports.Input = inputs[i/150]
+        mv.RunFrame(m)
+        if mid != nil {
+            mid.Inputs = append(mid.Inputs, m.Ports.Input)
+        }
+    }
+    want := sha256.Sum256(m.Mem.Ram)
+
+    for _, rec := range []*Movie{mv, mid, mv} {
+        var buf bytes.Buffer
+        if err := rec.Write(&buf); err != nil {
+            t.Fatal(err)
+        }
+        got, err := ReadMovie(&buf)
+        if err != nil {
+            t.Fatal(err)
+        }
+        played, err := got.Play(rom, nil)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if sha256.Sum256(played.Mem.Ram) != want {
+            t.Errorf("Expected replay of %d frames to end in the recorded RAM", len(got.Inputs))
+        }
+        if played.Ports.Ships != 5 {
+            t.Errorf("Expected dip switches to be replayed, got=%d ships", played.Ports.Ships)
+        }
+    }
+    if mv.Start != nil || mid.Start == nil {
+        t.Errorf("Expected a start state only mid session")
+    }
+
+    other := append([]uint8(nil), rom...)
+    other[0x100] ^= 0xff
+    if _, err := mv.Play(other, nil); err != ErrMovieROM {
+        t.Errorf("Expected ErrMovieROM, got=%v", err)
+    }
+    var buf bytes.Buffer
+    mv.Write(&buf)
+    data := buf.Bytes()
+    data[len(data)/2] ^= 1
+    if _, err := ReadMovie(bytes.NewReader(data)); err != ErrMovieCorrupt {
+        t.Errorf("Expected ErrMovieCorrupt, got=%v", err)
+    }
+}
+
+var update = flag.Bool("update", false, "rewrite testdata/golden.txt from the current run")
+
+// The golden run: from each frame on the input is held until the next
+// entry, screens are checked at goldenFrames.
+var (
+    goldenScript = []struct {
+        frame int
+        in    Input
+    }{
+        {0, 0},
+        {400, Coin},
+        {405, 0},
+        {500, P1Start},
+        {505, 0},
+        {700, P1Fire},
+        {702, P1Left},
+        {760, P1Fire | P1Right},
+        {900, 0},
+        {920, P1Fire},
+        {922, P1Right},
+        {1000, P1Fire | P1Left},
+        {1100, 0},
+    }
+    goldenFrames = []int{60, 200, 450, 600, 700, 800, 1000, 1200, 1500, 1800}
+)
+
+const goldenFile = "testdata/golden.txt"
+
+// TestGoldenFrames plays a scripted game and compares screen hashes with
+// the checked in ones, so a CPU change that alters the game shows up even
+// where no unit test looks. Run with -update after a deliberate change.
+func TestGoldenFrames(t *testing.T) {
+    m := New(loadROM(t))
+    got := map[int]string{}
+    step := 0
+    for frame := 0; frame < goldenFrames[len(goldenFrames)-1]; frame++ {
+        if step < len(goldenScript) && goldenScript[step].frame == frame {
+            m.Ports.Input = goldenScript[step].in
+            step++
+        }
+        m.RunFrame()
+        sum := sha1.Sum(m.VRAM())
+        got[frame+1] = hex.EncodeToString(sum[:])
+    }
+
+    if *update {
+        var b strings.Builder
+        b.WriteString("# frame, SHA1 of video RAM after it, see TestGoldenFrames\n")
+        for _, frame := range goldenFrames {
+            fmt.Fprintf(&b, "%d %s\n", frame, got[frame])
+        }
+        if err := os.WriteFile(goldenFile, []byte(b.String()), 0644); err != nil {
+            t.Fatal(err)
+        }
+        return
+    }
+
+    data, err := os.ReadFile(goldenFile)
+    if err != nil {
+        t.Fatalf("%v, run with -update to create it", err)
+    }
+    checked := 0
+    for _, line := range strings.Split(string(data), "\n") {
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        var frame int
+        var want string
+        if _, err := fmt.Sscan(line, &frame, &want); err != nil {
+            t.Fatalf("bad line %q in %s", line, goldenFile)
+        }
+        if got[frame] != want {
+            t.Errorf("Expected frame %d to hash to %s, got=%s", frame, want, got[frame])
+        }
+        checked++
+    }
+    if checked != len(goldenFrames) {
+        t.Errorf("Expected %d frames in %s, got=%d, run with -update", len(goldenFrames), goldenFile, checked)
+    }
+}
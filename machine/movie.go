@@ -0,0 +1,170 @@
+package machine
+
+import (
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "hash/crc32"
+    "io"
+)
+
+// Movie is a recorded session: the machine it started from and the inputs
+// held through each frame after. A machine is deterministic, so playing the
+// inputs back from the same start reproduces the session exactly.
+type Movie struct {
+    // CRC32 of the ROM, as in save states.
+    ROMSum uint32
+    // The dip switches, see Ports.
+    Ships      int
+    EarlyBonus bool
+    NoCoinInfo bool
+    // Start is a save state of where the recording began, nil when it
+    // began at power on.
+    Start []byte
+    // One entry per frame.
+    Inputs []Input
+}
+
+var (
+    ErrNotMovie     = errors.New("machine: not a movie")
+    ErrMovieCorrupt = errors.New("machine: movie is corrupt")
+    ErrMovieROM     = errors.New("machine: movie is for another ROM")
+)
+
+// RecordMovie starts a movie from m as it is now. A machine that hasn't
+// run yet is recorded as a power on, without a start state.
+func RecordMovie(m *Machine) (*Movie, error) {
+    mv := &Movie{
+        ROMSum:     crc32.ChecksumIEEE(m.rom),
+        Ships:      m.Ports.Ships,
+        EarlyBonus: m.Ports.EarlyBonus,
+        NoCoinInfo: m.Ports.NoCoinInfo,
+    }
+    if m.CPU.Cycles() != 0 {
+        var buf bytes.Buffer
+        if err := m.Save(&buf); err != nil {
+            return nil, err
+        }
+        mv.Start = buf.Bytes()
+    }
+
+    return mv, nil
+}
+
+// RunFrame runs a frame of m with its current input and records it.
+func (mv *Movie) RunFrame(m *Machine) {
+    mv.Inputs = append(mv.Inputs, m.Ports.Input)
+    m.RunFrame()
+}
+
+// Machine builds a machine the way it was when the recording began.
+func (mv *Movie) Machine(rom []uint8) (*Machine, error) {
+    if crc32.ChecksumIEEE(rom) != mv.ROMSum {
+        return nil, ErrMovieROM
+    }
+    m := New(rom)
+    m.Ports.Ships = mv.Ships
+    m.Ports.EarlyBonus = mv.EarlyBonus
+    m.Ports.NoCoinInfo = mv.NoCoinInfo
+    if mv.Start != nil {
+        if err := m.Load(bytes.NewReader(mv.Start)); err != nil {
+            return nil, err
+        }
+    }
+
+    return m, nil
+}
+
+// Play runs the whole movie on a fresh machine, calling each, if set,
+// after every frame. It returns the machine as the movie leaves it.
+func (mv *Movie) Play(rom []uint8, each func(m *Machine)) (*Machine, error) {
+    m, err := mv.Machine(rom)
+    if err != nil {
+        return nil, err
+    }
+    for _, in := range mv.Inputs {
+        m.Ports.Input = in
+        m.RunFrame()
+        if each != nil {
+            each(m)
+        }
+    }
+
+    return m, nil
+}
+
+// Movie files start with a 24 byte little endian header,
+//
+//    "INVMOVIE", version u16, ships u8, switches u8, ROM CRC32 u32,
+//    start state length u32, frames u32
+//
+// where switches has bit 0 for EarlyBonus and bit 1 for NoCoinInfo. The
+// start state follows as Save writes it, then the input of each frame as a
+// u16 and last a CRC32 of everything before it.
+const (
+    movieMagic      = "INVMOVIE"
+    movieVersion    = 1
+    movieHeaderSize = 24
+)
+
+// Write saves the movie to w.
+func (mv *Movie) Write(w io.Writer) error {
+    le := binary.LittleEndian
+    buf := make([]byte, movieHeaderSize, movieHeaderSize+len(mv.Start)+2*len(mv.Inputs)+4)
+    copy(buf, movieMagic)
+    le.PutUint16(buf[8:], movieVersion)
+    buf[10] = uint8(mv.Ships)
+    buf[11] = boolByte(mv.EarlyBonus) | boolByte(mv.NoCoinInfo)<<1
+    le.PutUint32(buf[12:], mv.ROMSum)
+    le.PutUint32(buf[16:], uint32(len(mv.Start)))
+    le.PutUint32(buf[20:], uint32(len(mv.Inputs)))
+    buf = append(buf, mv.Start...)
+    for _, in := range mv.Inputs {
+        buf = le.AppendUint16(buf, uint16(in))
+    }
+    buf = le.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+    _, err := w.Write(buf)
+
+    return err
+}
+
+// ReadMovie reads a movie written by Write.
+func ReadMovie(r io.Reader) (*Movie, error) {
+    le := binary.LittleEndian
+    header := make([]byte, movieHeaderSize)
+    if _, err := io.ReadFull(r, header); err != nil || string(header[:8]) != movieMagic {
+        return nil, ErrNotMovie
+    }
+    if le.Uint16(header[8:]) != movieVersion {
+        return nil, ErrVersion
+    }
+    startSize := le.Uint32(header[16:])
+    frames := le.Uint32(header[20:])
+    if startSize > 1<<20 || frames > 1<<28 {
+        return nil, ErrMovieCorrupt
+    }
+    body := make([]byte, int(startSize)+2*int(frames)+4)
+    if _, err := io.ReadFull(r, body); err != nil {
+        return nil, ErrMovieCorrupt
+    }
+    sum := crc32.Update(crc32.ChecksumIEEE(header), crc32.IEEETable, body[:len(body)-4])
+    if sum != le.Uint32(body[len(body)-4:]) {
+        return nil, ErrMovieCorrupt
+    }
+
+    mv := &Movie{
+        ROMSum:     le.Uint32(header[12:]),
+        Ships:      int(header[10]),
+        EarlyBonus: header[11]&1 != 0,
+        NoCoinInfo: header[11]&2 != 0,
+        Inputs:     make([]Input, frames),
+    }
+    if startSize > 0 {
+        mv.Start = body[:startSize]
+    }
+    for i := range mv.Inputs {
+        mv.Inputs[i] = Input(le.Uint16(body[int(startSize)+2*i:]))
+    }
+
+    return mv, nil
+}
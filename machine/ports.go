@@ -0,0 +1,149 @@
+package machine
+
+// Input is a set of cabinet controls, as held down at one moment.
+type Input uint16
+
+const (
+    Coin Input = 1 << iota
+    P1Start
+    P2Start
+    P1Fire
+    P1Left
+    P1Right
+    P2Fire
+    P2Left
+    P2Right
+    Tilt
+)
+
+// InputBit wires a control to a bit of an input port.
+type InputBit struct {
+    Input Input
+    Port  uint8
+    Mask  uint8
+}
+
+// InputLayout is how a game's cabinet is wired to input ports 0 to 2.
+type InputLayout struct {
+    // What each port reads with nothing pressed and the dip switches off.
+    // A control whose bit is set here is active low and clears it.
+    Idle     [3]uint8
+    Bits     []InputBit
+    Switches Switches
+}
+
+// Switches is where a game's dip switches sit on port 2. A mask of 0 means
+// the game has no such switch.
+type Switches struct {
+    // Ships are counted from MinShips up in the ShipMask bits, which start
+    // at bit 0.
+    MinShips, MaxShips int
+    ShipMask           uint8
+    EarlyBonus         uint8
+    NoCoinInfo         uint8
+}
+
+// InvadersInputs is the Space Invaders wiring.
+var InvadersInputs = InputLayout{
+    // Unused port 0 bits and bit 3 of port 1 are wired high.
+    Idle: [3]uint8{0x0e, 0x08, 0x00},
+    Bits: []InputBit{
+        {Coin, 1, 0x01},
+        {P2Start, 1, 0x02},
+        {P1Start, 1, 0x04},
+        {P1Fire, 1, 0x10},
+        {P1Left, 1, 0x20},
+        {P1Right, 1, 0x40},
+        {Tilt, 2, 0x04},
+        {P2Fire, 2, 0x10},
+        {P2Left, 2, 0x20},
+        {P2Right, 2, 0x40},
+    },
+    Switches: Switches{MinShips: 3, MaxShips: 6, ShipMask: 0x03, EarlyBonus: 0x08, NoCoinInfo: 0x80},
+}
+
+// Ports is the Space Invaders board as the CPU sees it through IN and OUT:
+// the two input ports, the dip switches, the hardware shift register used to
+// draw sprites at any x offset, and the sound latches.
+//
+//    IN 1   coin, starts and player 1 controls
+//    IN 2   dip switches, tilt and player 2 controls
+//    IN 3   shift register result
+//    OUT 2  shift amount
+//    OUT 3  sound latch 1
+//    OUT 4  shift data
+//    OUT 5  sound latch 2
+//    OUT 6  watchdog
+type Ports struct {
+    Input Input
+    // Layout wires Input to the ports, InvadersInputs unless a driver
+    // says otherwise.
+    Layout *InputLayout
+    // Ships per game, in the range Layout.Switches gives, 3 to 6 for Space
+    // Invaders.
+    Ships int
+    // Extra ship at 1000 points instead of 1500.
+    EarlyBonus bool
+    // Hides the coin info on the demo screen.
+    NoCoinInfo bool
+
+    // Last values written to the sound latches.
+    Sound1, Sound2 uint8
+
+    shift       uint16
+    shiftOffset uint8
+}
+
+func NewPorts() *Ports {
+    return &Ports{Ships: 3, Layout: &InvadersInputs}
+}
+
+func (p *Ports) In(port uint8) uint8 {
+    switch port {
+    case 0, 1:
+        return p.inputs(port)
+    case 2:
+        v := p.inputs(port)
+        sw := &p.Layout.Switches
+        if p.Ships > sw.MinShips {
+            v |= uint8(p.Ships-sw.MinShips) & sw.ShipMask
+        }
+        if p.EarlyBonus {
+            v |= sw.EarlyBonus
+        }
+        if p.NoCoinInfo {
+            v |= sw.NoCoinInfo
+        }
+        return v
+    case 3:
+        return uint8(p.shift >> (8 - p.shiftOffset))
+    }
+
+    return 0
+}
+
+func (p *Ports) Out(port uint8, data uint8) {
+    switch port {
+    case 2:
+        p.shiftOffset = data & 0x07
+    case 3:
+        p.Sound1 = data
+    case 4:
+        p.shift = uint16(data)<<8 | p.shift>>8
+    case 5:
+        p.Sound2 = data
+    }
+    // Port 6 feeds the watchdog, which we don't emulate.
+}
+
+// inputs is port as Layout wires the controls held.
+func (p *Ports) inputs(port uint8) uint8 {
+    v := p.Layout.Idle[port]
+    for _, b := range p.Layout.Bits {
+        if b.Port == port && p.Input&b.Input != 0 {
+            v ^= b.Mask
+        }
+    }
+
+    return v
+}
@@ -0,0 +1,161 @@
+package machine
+
+import "encoding/binary"
+
+// Rewind keeps a trail of machine states to step back through. Only the
+// newest state is kept whole, every older one is stored as the difference
+// to the state after it, XORed and run length encoded. Between two
+// captures a few frames apart most of RAM, and all of the ROM area, doesn't
+// change, so a difference is usually a few hundred bytes rather than 16K.
+type Rewind struct {
+    // Frames between captures.
+    Interval int
+    // Bytes the differences may use, the oldest are dropped past it.
+    Budget int
+
+    head    []byte
+    spare   []byte
+    hasHead bool
+    deltas  [][]byte
+    size    int
+    scratch State
+}
+
+// What the frontends rewind with: a capture every 4 frames in 4M, which at
+// a few hundred bytes a difference is minutes of play.
+const (
+    RewindInterval = 4
+    RewindBudget   = 4 << 20
+)
+
+// NewRewind captures every interval frames within budget bytes.
+func NewRewind(interval, budget int) *Rewind {
+    if interval < 1 {
+        interval = 1
+    }
+    return &Rewind{
+        Interval: interval,
+        Budget:   budget,
+        head:     make([]byte, StateSize),
+        spare:    make([]byte, StateSize),
+    }
+}
+
+// Capture is called after every frame and takes a snapshot when one is due.
+func (r *Rewind) Capture(m *Machine) {
+    if m.Frame%uint64(r.Interval) == 0 {
+        r.Push(m)
+    }
+}
+
+// Push snapshots the machine now.
+func (r *Rewind) Push(m *Machine) {
+    m.SaveState(&r.scratch)
+    if !r.hasHead {
+        r.scratch.encode(r.head)
+        r.hasHead = true
+        return
+    }
+    r.scratch.encode(r.spare)
+    delta := encodeDelta(r.head, r.spare)
+    r.deltas = append(r.deltas, delta)
+    r.size += len(delta)
+    r.head, r.spare = r.spare, r.head
+    for r.size > r.Budget && len(r.deltas) > 0 {
+        r.size -= len(r.deltas[0])
+        r.deltas[0] = nil
+        r.deltas = r.deltas[1:]
+    }
+}
+
+// Back restores the newest snapshot and forgets it, so the next call goes
+// one further back. It reports false once there is nothing left.
+func (r *Rewind) Back(m *Machine) bool {
+    if !r.hasHead {
+        return false
+    }
+    r.scratch.decode(r.head)
+    m.LoadState(&r.scratch)
+
+    if n := len(r.deltas); n > 0 {
+        delta := r.deltas[n-1]
+        applyDelta(r.head, delta)
+        r.size -= len(delta)
+        r.deltas[n-1] = nil
+        r.deltas = r.deltas[:n-1]
+    } else {
+        r.hasHead = false
+    }
+
+    return true
+}
+
+// Len is the number of snapshots held.
+func (r *Rewind) Len() int {
+    if !r.hasHead {
+        return 0
+    }
+    return len(r.deltas) + 1
+}
+
+// Size is the bytes used by the differences.
+func (r *Rewind) Size() int {
+    return r.size
+}
+
+// Frames is how far back the trail reaches.
+func (r *Rewind) Frames() int {
+    if !r.hasHead {
+        return 0
+    }
+    return len(r.deltas) * r.Interval
+}
+
+func (r *Rewind) Reset() {
+    r.hasHead = false
+    r.deltas = nil
+    r.size = 0
+}
+
+// encodeDelta describes old as changes to cur: pairs of a run of unchanged
+// bytes and a run of changed ones as uvarint lengths, the changed run
+// followed by its bytes XORed with cur.
+func encodeDelta(old, cur []byte) []byte {
+    var out []byte
+    var tmp [binary.MaxVarintLen64]byte
+    for i := 0; i < len(old); {
+        same := i
+        for same < len(old) && old[same] == cur[same] {
+            same++
+        }
+        diff := same
+        for diff < len(old) && old[diff] != cur[diff] {
+            diff++
+        }
+        out = append(out, tmp[:binary.PutUvarint(tmp[:], uint64(same-i))]...)
+        out = append(out, tmp[:binary.PutUvarint(tmp[:], uint64(diff-same))]...)
+        for j := same; j < diff; j++ {
+            out = append(out, old[j]^cur[j])
+        }
+        i = diff
+    }
+
+    return out
+}
+
+// applyDelta turns cur back into the old state encodeDelta described.
+func applyDelta(cur []byte, delta []byte) {
+    i := 0
+    for len(delta) > 0 {
+        same, n := binary.Uvarint(delta)
+        delta = delta[n:]
+        diff, n := binary.Uvarint(delta)
+        delta = delta[n:]
+        i += int(same)
+        for j := 0; j < int(diff); j++ {
+            cur[i+j] ^= delta[j]
+        }
+        i += int(diff)
+        delta = delta[diff:]
+    }
+}
@@ -0,0 +1,40 @@
+package machine
+
+import "image"
+
+// The monitor is mounted on its side, so the upright picture is 224 pixels
+// wide and 256 high.
+const (
+    ScreenWidth  = 224
+    ScreenHeight = 256
+)
+
+// Pixel reports whether x, y of the upright picture is lit, 0, 0 being the
+// top left. vram is laid out as VRAM returns it.
+func Pixel(vram []uint8, x, y int) bool {
+    row := ScreenHeight - 1 - y
+    return vram[x*32+row/8]>>(row%8)&1 != 0
+}
+
+// RenderScreen draws vram upright into img, lit pixels white. img must be
+// ScreenWidth by ScreenHeight.
+func RenderScreen(vram []uint8, img *image.Gray) {
+    for y := 0; y < ScreenHeight; y++ {
+        line := img.Pix[y*img.Stride : y*img.Stride+ScreenWidth]
+        for x := range line {
+            if Pixel(vram, x, y) {
+                line[x] = 0xff
+            } else {
+                line[x] = 0
+            }
+        }
+    }
+}
+
+// Screen renders the current picture.
+func (m *Machine) Screen() *image.Gray {
+    img := image.NewGray(image.Rect(0, 0, ScreenWidth, ScreenHeight))
+    RenderScreen(m.VRAM(), img)
+
+    return img
+}
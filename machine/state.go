@@ -0,0 +1,266 @@
+package machine
+
+import (
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "io"
+)
+
+// State is everything needed to resume a machine exactly. It has a fixed
+// size and layout, the file format below is this struct in little endian
+// order, packed.
+type State struct {
+    A, B, C, D, E, H, L, Flags uint8
+    SP, PC                     uint16
+    IntEnable, Halted          bool
+    Cycles                     uint64
+
+    RAM [0x2000]uint8
+
+    Input          uint16
+    Ships          uint8
+    EarlyBonus     bool
+    NoCoinInfo     bool
+    Sound1, Sound2 uint8
+    Shift          uint16
+    ShiftOffset    uint8
+
+    Frame      uint64
+    NextInt    uint64
+    NextVector uint8
+
+    // The ROM area at 0000 as the machine has it, since the guest or a
+    // debugger can write to it.
+    ROM [ROMSize]uint8
+    // Layout is layoutSum of the input wiring, which Input is read
+    // through.
+    Layout uint32
+}
+
+// StateSize is the encoded size of a State.
+const StateSize = 22 + 0x2000 + 10 + 17 + ROMSize + 4
+
+// stateSizeV1 is StateSize before the ROM area and Layout were added.
+const stateSizeV1 = 22 + 0x2000 + 10 + 17
+
+// encode writes s to buf, StateSize bytes, in the same layout
+// binary.Write would give it.
+func (s *State) encode(buf []byte) {
+    le := binary.LittleEndian
+    buf[0], buf[1], buf[2], buf[3] = s.A, s.B, s.C, s.D
+    buf[4], buf[5], buf[6], buf[7] = s.E, s.H, s.L, s.Flags
+    le.PutUint16(buf[8:], s.SP)
+    le.PutUint16(buf[10:], s.PC)
+    buf[12], buf[13] = boolByte(s.IntEnable), boolByte(s.Halted)
+    le.PutUint64(buf[14:], s.Cycles)
+    copy(buf[22:], s.RAM[:])
+    p := buf[22+0x2000:]
+    le.PutUint16(p[0:], s.Input)
+    p[2], p[3], p[4] = s.Ships, boolByte(s.EarlyBonus), boolByte(s.NoCoinInfo)
+    p[5], p[6] = s.Sound1, s.Sound2
+    le.PutUint16(p[7:], s.Shift)
+    p[9] = s.ShiftOffset
+    le.PutUint64(p[10:], s.Frame)
+    le.PutUint64(p[18:], s.NextInt)
+    p[26] = s.NextVector
+    copy(p[27:], s.ROM[:])
+    le.PutUint32(p[27+ROMSize:], s.Layout)
+}
+
+func (s *State) decode(buf []byte) {
+    le := binary.LittleEndian
+    s.A, s.B, s.C, s.D = buf[0], buf[1], buf[2], buf[3]
+    s.E, s.H, s.L, s.Flags = buf[4], buf[5], buf[6], buf[7]
+    s.SP = le.Uint16(buf[8:])
+    s.PC = le.Uint16(buf[10:])
+    s.IntEnable, s.Halted = buf[12] != 0, buf[13] != 0
+    s.Cycles = le.Uint64(buf[14:])
+    copy(s.RAM[:], buf[22:])
+    p := buf[22+0x2000:]
+    s.Input = le.Uint16(p[0:])
+    s.Ships, s.EarlyBonus, s.NoCoinInfo = p[2], p[3] != 0, p[4] != 0
+    s.Sound1, s.Sound2 = p[5], p[6]
+    s.Shift = le.Uint16(p[7:])
+    s.ShiftOffset = p[9]
+    s.Frame = le.Uint64(p[10:])
+    s.NextInt = le.Uint64(p[18:])
+    s.NextVector = p[26]
+    copy(s.ROM[:], p[27:])
+    s.Layout = le.Uint32(p[27+ROMSize:])
+}
+
+// layoutSum is a CRC32 of an input layout, for telling whether a state was
+// saved with the same wiring.
+func layoutSum(l *InputLayout) uint32 {
+    buf := append([]byte(nil), l.Idle[:]...)
+    for _, b := range l.Bits {
+        buf = append(buf, byte(b.Input), byte(b.Input>>8), b.Port, b.Mask)
+    }
+    sw := l.Switches
+    buf = append(buf, byte(sw.MinShips), byte(sw.MaxShips), sw.ShipMask, sw.EarlyBonus, sw.NoCoinInfo)
+
+    return crc32.ChecksumIEEE(buf)
+}
+
+func boolByte(b bool) uint8 {
+    if b {
+        return 1
+    }
+    return 0
+}
+
+// SaveState copies the machine into s.
+func (m *Machine) SaveState(s *State) {
+    c := m.CPU
+    s.A, s.B, s.C, s.D, s.E, s.H, s.L, s.Flags = c.A, c.B, c.C, c.D, c.E, c.H, c.L, c.Flags
+    s.SP, s.PC = c.SP, c.PC
+    s.IntEnable, s.Halted = c.IntEnable, c.Halted
+    s.Cycles = c.Cycles()
+    copy(s.RAM[:], m.Mem.Ram)
+    copy(s.ROM[:], m.Mem.Rom)
+
+    p := m.Ports
+    s.Input = uint16(p.Input)
+    s.Ships = uint8(p.Ships)
+    s.EarlyBonus, s.NoCoinInfo = p.EarlyBonus, p.NoCoinInfo
+    s.Sound1, s.Sound2 = p.Sound1, p.Sound2
+    s.Shift, s.ShiftOffset = p.shift, p.shiftOffset
+    s.Layout = layoutSum(p.Layout)
+
+    s.Frame, s.NextInt, s.NextVector = m.Frame, m.nextInt, m.nextVector
+}
+
+// LoadState puts the machine back the way s has it. The input layout stays
+// the machine's own, Load is what checks it matches.
+func (m *Machine) LoadState(s *State) {
+    c := m.CPU
+    c.A, c.B, c.C, c.D, c.E, c.H, c.L, c.Flags = s.A, s.B, s.C, s.D, s.E, s.H, s.L, s.Flags
+    c.SP, c.PC = s.SP, s.PC
+    c.IntEnable, c.Halted = s.IntEnable, s.Halted
+    c.SetCycles(s.Cycles)
+    copy(m.Mem.Ram, s.RAM[:])
+    copy(m.Mem.Rom, s.ROM[:])
+
+    p := m.Ports
+    p.Input = Input(s.Input)
+    p.Ships = int(s.Ships)
+    p.EarlyBonus, p.NoCoinInfo = s.EarlyBonus, s.NoCoinInfo
+    p.Sound1, p.Sound2 = s.Sound1, s.Sound2
+    p.shift, p.shiftOffset = s.Shift, s.ShiftOffset
+
+    m.Frame, m.nextInt, m.nextVector = s.Frame, s.NextInt, s.NextVector
+}
+
+// Save state files start with a 24 byte little endian header,
+//
+//    "INVSTATE", version u16, pad u16, ROM CRC32 u32, payload length u32,
+//    payload CRC32 u32
+//
+// followed by the payload, which for the current version is State. A file
+// from an older version is brought up to date by running its payload
+// through migrations in turn, so whenever State changes the version goes up
+// and the function turning the previous payload into the new one goes in
+// here. A migration is given the machine being loaded into, for what an
+// older payload left out.
+const (
+    stateMagic   = "INVSTATE"
+    stateVersion = 2
+    headerSize   = 24
+)
+
+var migrations = map[uint16]func(m *Machine, payload []byte) ([]byte, error){
+    1: migrateV1,
+}
+
+// migrateV1 adds the ROM area and Layout. Version 1 didn't keep writes to
+// the ROM area, so the best there is is the ROM as loaded.
+func migrateV1(m *Machine, payload []byte) ([]byte, error) {
+    if len(payload) != stateSizeV1 {
+        return nil, ErrChecksum
+    }
+    payload = append(payload, m.rom[:ROMSize]...)
+
+    return binary.LittleEndian.AppendUint32(payload, layoutSum(m.Ports.Layout)), nil
+}
+
+var (
+    ErrNotState   = errors.New("machine: not a save state")
+    ErrChecksum   = errors.New("machine: save state is corrupt")
+    ErrVersion    = errors.New("machine: save state is from a newer version")
+    ErrROMChanged = errors.New("machine: save state is for another ROM")
+    ErrLayout     = errors.New("machine: save state is for other input wiring")
+)
+
+// Save writes the machine's state to w.
+func (m *Machine) Save(w io.Writer) error {
+    var s State
+    m.SaveState(&s)
+    payload := make([]byte, StateSize)
+    s.encode(payload)
+
+    header := make([]byte, headerSize)
+    copy(header, stateMagic)
+    binary.LittleEndian.PutUint16(header[8:], stateVersion)
+    binary.LittleEndian.PutUint32(header[12:], crc32.ChecksumIEEE(m.rom))
+    binary.LittleEndian.PutUint32(header[16:], uint32(len(payload)))
+    binary.LittleEndian.PutUint32(header[20:], crc32.ChecksumIEEE(payload))
+    if _, err := w.Write(header); err != nil {
+        return err
+    }
+    _, err := w.Write(payload)
+
+    return err
+}
+
+// Load restores a state written by Save. The machine must be running the
+// same ROM with the same input wiring. Nothing changes when an error is
+// returned.
+func (m *Machine) Load(r io.Reader) error {
+    header := make([]byte, headerSize)
+    if _, err := io.ReadFull(r, header); err != nil || string(header[:8]) != stateMagic {
+        return ErrNotState
+    }
+    version := binary.LittleEndian.Uint16(header[8:])
+    romSum := binary.LittleEndian.Uint32(header[12:])
+    size := binary.LittleEndian.Uint32(header[16:])
+    if version > stateVersion {
+        return ErrVersion
+    }
+    if size > 1<<20 {
+        return ErrChecksum
+    }
+    payload := make([]byte, size)
+    if _, err := io.ReadFull(r, payload); err != nil {
+        return ErrChecksum
+    }
+    if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[20:]) {
+        return ErrChecksum
+    }
+    if romSum != crc32.ChecksumIEEE(m.rom) {
+        return ErrROMChanged
+    }
+
+    for ; version < stateVersion; version++ {
+        migrate, ok := migrations[version]
+        if !ok {
+            return fmt.Errorf("machine: no migration from save state version %d", version)
+        }
+        var err error
+        if payload, err = migrate(m, payload); err != nil {
+            return err
+        }
+    }
+    if len(payload) != StateSize {
+        return ErrChecksum
+    }
+    var s State
+    s.decode(payload)
+    if s.Layout != layoutSum(m.Ports.Layout) {
+        return ErrLayout
+    }
+    m.LoadState(&s)
+
+    return nil
+}
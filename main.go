@@ -1,32 +1,222 @@
 package main
 
 import (
-	"fmt"
-	"os"
+    "errors"
+    "flag"
+    "fmt"
+    "os"
 
-    "github.com/siathema/goInvadeSpace/core"
-    "github.com/siathema/goInvadeSpace/memory"
+    "github.com/siathema/goInvadeSpace/driver"
+    "github.com/siathema/goInvadeSpace/machine"
+    "github.com/siathema/goInvadeSpace/trace"
 )
 
+const progName = "goInvadeSpace"
+
+type command struct {
+    name    string
+    summary string
+    run     func(args []string) error
+}
+
+var commands = []command{
+    {"run", "play a game, headless or in a frontend", runCmd},
+    {"debug", "run a game under the debugger, or serve it to gdb", debugCmd},
+    {"disasm", "disassemble a game's ROM", disasmCmd},
+    {"test-rom", "run CP/M CPU test programs and check they pass", testROMCmd},
+}
+
+func usage() {
+    w := os.Stderr
+    fmt.Fprintf(w, "usage: %s COMMAND [flags] [args]\n\ncommands:\n", progName)
+    for _, c := range commands {
+        fmt.Fprintf(w, "  %-9s %s\n", c.name, c.summary)
+    }
+    fmt.Fprintf(w, "\nRun %s COMMAND -h for a command's flags. Games: %v\n", progName, driver.Names())
+}
+
+// Exit codes: 0 for success, 1 when the command failed, 2 for bad usage.
 func main() {
-    fmt.Println("Hello weeb!")
-    romData, err := os.ReadFile("roms/invaders.rom")
+    if len(os.Args) < 2 {
+        usage()
+        os.Exit(2)
+    }
+    name := os.Args[1]
+    switch name {
+    case "-h", "-help", "--help", "help":
+        usage()
+        return
+    }
+    for _, c := range commands {
+        if c.name != name {
+            continue
+        }
+        err := c.run(os.Args[2:])
+        var uerr usageError
+        switch {
+        case err == nil, errors.Is(err, flag.ErrHelp):
+        case errors.Is(err, errFlags):
+            // The flag package has already said what was wrong.
+            os.Exit(2)
+        case errors.As(err, &uerr):
+            fmt.Fprintf(os.Stderr, "%s %s: %v\n", progName, name, err)
+            os.Exit(2)
+        default:
+            fmt.Fprintf(os.Stderr, "%s %s: %v\n", progName, name, err)
+            os.Exit(1)
+        }
+        return
+    }
+    fmt.Fprintf(os.Stderr, "%s: unknown command %q\n", progName, name)
+    usage()
+    os.Exit(2)
+}
+
+// usageError is a mistake on the command line rather than a failure.
+type usageError string
+
+func (e usageError) Error() string {
+    return string(e)
+}
+
+var errFlags = errors.New("bad flags")
+
+func newFlagSet(name, args string) *flag.FlagSet {
+    fs := flag.NewFlagSet(name, flag.ContinueOnError)
+    fs.Usage = func() {
+        fmt.Fprintf(fs.Output(), "usage: %s %s [flags]", progName, name)
+        if args != "" {
+            fmt.Fprintf(fs.Output(), " %s", args)
+        }
+        fmt.Fprintln(fs.Output())
+        fs.PrintDefaults()
+    }
+
+    return fs
+}
+
+// parse parses a command's flags, leaving the flag package to report
+// mistakes.
+func parse(fs *flag.FlagSet, args []string) error {
+    if err := fs.Parse(args); err != nil {
+        if errors.Is(err, flag.ErrHelp) {
+            return err
+        }
+        return errFlags
+    }
+
+    return nil
+}
+
+// romFlags pick a game and where its ROMs are.
+type romFlags struct {
+    game string
+    rom  string
+}
+
+func addROMFlags(fs *flag.FlagSet) *romFlags {
+    f := &romFlags{}
+    fs.StringVar(&f.game, "game", "invaders", fmt.Sprintf("the game, one of %v", driver.Names()))
+    fs.StringVar(&f.rom, "rom", "roms", "ROM set: a directory, a zip or a whole image")
+
+    return f
+}
+
+func (f *romFlags) load() (*driver.Driver, []uint8, error) {
+    game, err := driver.Lookup(f.game)
     if err != nil {
-        panic(err)
+        return nil, nil, usageError(err.Error())
     }
+    rom, err := game.ROMs.Load(f.rom)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    return game, rom, nil
+}
+
+// machineFlags set up a machine: the game, its dip switches and tracing.
+type machineFlags struct {
+    *romFlags
+    ships       int
+    earlyBonus  bool
+    noCoinInfo  bool
+    traceFile   string
+    traceFormat string
+
+    fs *flag.FlagSet
+}
+
+func addMachineFlags(fs *flag.FlagSet) *machineFlags {
+    f := &machineFlags{romFlags: addROMFlags(fs), fs: fs}
+    fs.IntVar(&f.ships, "ships", 3, "dip switch: ships per game, 3 to 6 for invaders")
+    fs.BoolVar(&f.earlyBonus, "early-bonus", false, "dip switch: extra ship at 1000 points instead of 1500")
+    fs.BoolVar(&f.noCoinInfo, "no-coin-info", false, "dip switch: hide the coin info on the demo screen")
+    fs.StringVar(&f.traceFile, "trace", "", "write an execution trace to this file")
+    fs.StringVar(&f.traceFormat, "trace-format", "text", "trace format, text or bin")
 
-    core.New()
-    mem := new(memory.MainMemory)
-    mem.Init(romData)
-    fmt.Printf("Memory Initialized with %dK of rom and %dK of ram!\n",
-        len(mem.Rom)/1024, len(mem.Ram)/1024)
+    return f
+}
 
-    for {
-        core.RunTick(mem)
-        if core.PC == uint16(memory.Kilobytes(8)) {
-            break
+// setDIPs applies the dip switches given on the command line over the
+// game's defaults.
+func (f *machineFlags) setDIPs(m *machine.Machine) error {
+    sw := &m.Ports.Layout.Switches
+    var err error
+    f.fs.Visit(func(fl *flag.Flag) {
+        switch {
+        case err != nil:
+        case fl.Name == "ships" && (f.ships < sw.MinShips || f.ships > sw.MaxShips):
+            err = usageError(fmt.Sprintf("-ships %d is not %d to %d for %s", f.ships, sw.MinShips, sw.MaxShips, f.game))
+        case fl.Name == "ships":
+            m.Ports.Ships = f.ships
+        case fl.Name == "early-bonus" && sw.EarlyBonus == 0, fl.Name == "no-coin-info" && sw.NoCoinInfo == 0:
+            err = usageError(fmt.Sprintf("%s has no -%s switch", f.game, fl.Name))
+        case fl.Name == "early-bonus":
+            m.Ports.EarlyBonus = f.earlyBonus
+        case fl.Name == "no-coin-info":
+            m.Ports.NoCoinInfo = f.noCoinInfo
         }
+    })
+
+    return err
+}
+
+// startTrace attaches a tracer to m when -trace is given. The returned
+// function flushes and closes it, calls after the first do nothing.
+func (f *machineFlags) startTrace(game *driver.Driver, m *machine.Machine) (func() error, error) {
+    if f.traceFile == "" {
+        return func() error { return nil }, nil
+    }
+    if f.traceFormat != "text" && f.traceFormat != "bin" {
+        return nil, usageError(fmt.Sprintf("unknown trace format %q, want text or bin", f.traceFormat))
     }
-    //fmt.Println(memory.Rom)
-    //fmt.Println(len(romData))
+    file, err := os.Create(f.traceFile)
+    if err != nil {
+        return nil, err
+    }
+    var flush func() error
+    if f.traceFormat == "text" {
+        w := trace.NewTextWriter(file)
+        w.Syms = game.Syms()
+        m.CPU.Tracer = w
+        flush = w.Flush
+    } else {
+        w := trace.NewBinaryWriter(file)
+        m.CPU.Tracer = w
+        flush = w.Flush
+    }
+
+    closed := false
+    return func() error {
+        if closed {
+            return nil
+        }
+        closed = true
+        err := flush()
+        if cerr := file.Close(); err == nil {
+            err = cerr
+        }
+        return err
+    }, nil
 }
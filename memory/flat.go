@@ -0,0 +1,27 @@
+package memory
+
+// FlatMemory is 64K of RAM with nothing else mapped, the way CP/M and the
+// CPU test suites expect the machine to look.
+type FlatMemory struct {
+    Data []uint8
+}
+
+func NewFlatMemory() *FlatMemory {
+    return &FlatMemory{Data: make([]uint8, 0x10000)}
+}
+
+func (mem *FlatMemory) Read(addr uint16) uint8 {
+    return mem.Data[addr]
+}
+
+func (mem *FlatMemory) Write(addr uint16, data uint8) error {
+    mem.Data[addr] = data
+    return nil
+}
+
+// Load copies data in starting at addr, wrapping past FFFF.
+func (mem *FlatMemory) Load(addr uint16, data []uint8) {
+    for i, b := range data {
+        mem.Data[addr+uint16(i)] = b
+    }
+}
@@ -0,0 +1,98 @@
+package memory
+
+type Access uint8
+
+const (
+    AccessRead Access = 1 << iota
+    AccessWrite
+    AccessReadWrite = AccessRead | AccessWrite
+)
+
+func (a Access) String() string {
+    switch a {
+    case AccessRead:
+        return "read"
+    case AccessWrite:
+        return "write"
+    case AccessReadWrite:
+        return "read/write"
+    }
+    return "none"
+}
+
+// Hook gets called for every watched access. For reads data is the value
+// returned to the CPU, for writes it is the value being stored.
+type Hook func(access Access, addr uint16, data uint8)
+
+type watch struct {
+    id     int
+    lo, hi uint16
+    access Access
+    hook   Hook
+}
+
+// HookedMemory forwards to another Memory and calls hooks for accesses that
+// fall in a watched range. With no watches set it only costs a length check.
+type HookedMemory struct {
+    Memory
+    watches []watch
+    nextID  int
+}
+
+func NewHookedMemory(mem Memory) *HookedMemory {
+    return &HookedMemory{Memory: mem, nextID: 1}
+}
+
+// Watch calls hook for every access of the given kind to an address in
+// lo..hi inclusive and returns an id for Unwatch.
+func (h *HookedMemory) Watch(lo, hi uint16, access Access, hook Hook) int {
+    if hi < lo {
+        lo, hi = hi, lo
+    }
+    id := h.nextID
+    h.nextID++
+    h.watches = append(h.watches, watch{id: id, lo: lo, hi: hi, access: access, hook: hook})
+
+    return id
+}
+
+func (h *HookedMemory) Unwatch(id int) bool {
+    for i, w := range h.watches {
+        if w.id == id {
+            h.watches = append(h.watches[:i], h.watches[i+1:]...)
+            return true
+        }
+    }
+
+    return false
+}
+
+func (h *HookedMemory) UnwatchAll() {
+    h.watches = nil
+}
+
+func (h *HookedMemory) Read(addr uint16) uint8 {
+    data := h.Memory.Read(addr)
+    if len(h.watches) != 0 {
+        h.fire(AccessRead, addr, data)
+    }
+
+    return data
+}
+
+func (h *HookedMemory) Write(addr uint16, data uint8) error {
+    err := h.Memory.Write(addr, data)
+    if len(h.watches) != 0 {
+        h.fire(AccessWrite, addr, data)
+    }
+
+    return err
+}
+
+func (h *HookedMemory) fire(access Access, addr uint16, data uint8) {
+    for _, w := range h.watches {
+        if w.access&access != 0 && addr >= w.lo && addr <= w.hi {
+            w.hook(access, addr, data)
+        }
+    }
+}
@@ -8,10 +8,18 @@ func Kilobytes(kb uint) uint {
     return kb * 1024
 }
 
+// Memory is everything the CPU can see on its address bus.
+type Memory interface {
+    Read(addr uint16) uint8
+    Write(addr uint16, data uint8) error
+}
+
 // Use for main memory and io memory
 type MemoryMap struct {
     WriteEnable bool
     Rom, Ram []uint8
+    // ExtRom is mapped from 4000 up, for boards with more than 8K of ROM.
+    ExtRom []uint8
 }
 
 type MainMemory MemoryMap
@@ -35,6 +43,8 @@ func (mem *MainMemory) Read(addr uint16) uint8 {
         return mem.Rom[addr]
     } else if uint(addr) < Kilobytes(16){
         return mem.Ram[addr - uint16(Kilobytes(8))] 
+    } else if int(addr) < 0x4000 + len(mem.ExtRom) {
+        return mem.ExtRom[addr - 0x4000]
     } else {
         // this needs to error out
         return 0
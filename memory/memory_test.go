@@ -1 +1,53 @@
 package memory
+
+import (
+    "testing"
+)
+
+func TestHookedMemory(t *testing.T) {
+    type event struct {
+        access Access
+        addr   uint16
+        data   uint8
+    }
+    var events []event
+    record := func(access Access, addr uint16, data uint8) {
+        events = append(events, event{access, addr, data})
+    }
+
+    h := NewHookedMemory(NewMainMemory(nil))
+    id := h.Watch(0x20F8, 0x20FB, AccessWrite, record)
+    h.Watch(0x2100, 0x2100, AccessReadWrite, record)
+
+    h.Write(0x20F7, 0x01)
+    h.Write(0x20F8, 0x02)
+    h.Read(0x20F8)
+    h.Write(0x2100, 0x03)
+    h.Read(0x2100)
+
+    expected := []event{
+        {AccessWrite, 0x20F8, 0x02},
+        {AccessWrite, 0x2100, 0x03},
+        {AccessRead, 0x2100, 0x03},
+    }
+    if len(events) != len(expected) {
+        t.Fatalf("Expected %d events, got=%v", len(expected), events)
+    }
+    for i := range expected {
+        if events[i] != expected[i] {
+            t.Errorf("Expected events[%d]=%v, got=%v", i, expected[i], events[i])
+        }
+    }
+
+    if !h.Unwatch(id) || h.Unwatch(id) {
+        t.Errorf("Expected Unwatch to succeed exactly once")
+    }
+    events = nil
+    h.Write(0x20F9, 0x04)
+    if len(events) != 0 {
+        t.Errorf("Expected no events after Unwatch, got=%v", events)
+    }
+    if h.Read(0x20F9) != 0x04 {
+        t.Errorf("Expected writes to reach the wrapped memory")
+    }
+}
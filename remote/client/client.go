@@ -0,0 +1,103 @@
+// Package client drives an emulator process serving the remote package's
+// JSON-RPC API.
+package client
+
+import (
+    "bytes"
+    "image"
+    "image/png"
+    "net/rpc"
+    "net/rpc/jsonrpc"
+
+    "github.com/siathema/goInvadeSpace/machine"
+    "github.com/siathema/goInvadeSpace/remote"
+)
+
+type Client struct {
+    rpc *rpc.Client
+}
+
+// Dial connects to a server on a "tcp" address or "unix" socket path.
+func Dial(network, addr string) (*Client, error) {
+    c, err := jsonrpc.Dial(network, addr)
+    if err != nil {
+        return nil, err
+    }
+
+    return &Client{rpc: c}, nil
+}
+
+func (c *Client) Close() error {
+    return c.rpc.Close()
+}
+
+func (c *Client) call(method string, args, reply any) error {
+    return c.rpc.Call(remote.ServiceName+"."+method, args, reply)
+}
+
+// LoadROMFile has the server load a ROM from its own file system.
+func (c *Client) LoadROMFile(path string) error {
+    return c.call("LoadROM", remote.LoadROMArgs{Path: path}, &remote.Empty{})
+}
+
+// LoadGame has the server load a game's ROM set from its own file system,
+// checking every chip. path is a directory, zip or whole image.
+func (c *Client) LoadGame(game, path string) error {
+    return c.call("LoadROM", remote.LoadROMArgs{Path: path, Game: game}, &remote.Empty{})
+}
+
+// LoadROM sends the ROM image to the server.
+func (c *Client) LoadROM(rom []byte) error {
+    return c.call("LoadROM", remote.LoadROMArgs{Data: rom}, &remote.Empty{})
+}
+
+func (c *Client) Reset() error {
+    return c.call("Reset", remote.Empty{}, &remote.Empty{})
+}
+
+// StepFrames runs n frames and returns the frame count after them.
+func (c *Client) StepFrames(n int) (uint64, error) {
+    var reply remote.StepReply
+    err := c.call("StepFrames", remote.StepArgs{Frames: n}, &reply)
+
+    return reply.Frame, err
+}
+
+func (c *Client) SetInput(in machine.Input) error {
+    return c.call("SetInput", remote.InputArgs{Input: in}, &remote.Empty{})
+}
+
+func (c *Client) Registers() (remote.Registers, error) {
+    var reply remote.Registers
+    err := c.call("Registers", remote.Empty{}, &reply)
+
+    return reply, err
+}
+
+func (c *Client) ReadMemory(addr uint16, n int) ([]byte, error) {
+    var reply []byte
+    err := c.call("ReadMemory", remote.MemoryArgs{Addr: addr, Len: n}, &reply)
+
+    return reply, err
+}
+
+// Screenshot returns the current frame.
+func (c *Client) Screenshot() (image.Image, error) {
+    var reply []byte
+    if err := c.call("Screenshot", remote.Empty{}, &reply); err != nil {
+        return nil, err
+    }
+
+    return png.Decode(bytes.NewReader(reply))
+}
+
+func (c *Client) SaveState() ([]byte, error) {
+    var reply []byte
+    err := c.call("SaveState", remote.Empty{}, &reply)
+
+    return reply, err
+}
+
+func (c *Client) LoadState(state []byte) error {
+    return c.call("LoadState", state, &remote.Empty{})
+}
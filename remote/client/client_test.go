@@ -0,0 +1,127 @@
+package client
+
+import (
+    "net"
+    "os"
+    "strings"
+    "testing"
+
+    "github.com/siathema/goInvadeSpace/machine"
+    "github.com/siathema/goInvadeSpace/memory"
+    "github.com/siathema/goInvadeSpace/remote"
+)
+
+func dialTest(t *testing.T) *Client {
+    l, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { l.Close() })
+    go remote.NewService(nil, nil).Serve(l)
+    c, err := Dial("tcp", l.Addr().String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { c.Close() })
+
+    return c
+}
+
+func TestClient(t *testing.T) {
+    c := dialTest(t)
+    if _, err := c.StepFrames(1); err == nil || err.Error() != remote.ErrNoROM.Error() {
+        t.Fatalf("Expected %v before a ROM is loaded, got=%v", remote.ErrNoROM, err)
+    }
+
+    rom := make([]byte, memory.Kilobytes(8))
+    // MVI A,42; STA 2000; IN 1; STA 2001; JMP 0005
+    copy(rom, []byte{0x3e, 0x42, 0x32, 0x00, 0x20, 0xdb, 0x01, 0x32, 0x01, 0x20, 0xc3, 0x05, 0x00})
+    if err := c.LoadROM(rom); err != nil {
+        t.Fatal(err)
+    }
+    if err := c.SetInput(machine.Coin); err != nil {
+        t.Fatal(err)
+    }
+    frame, err := c.StepFrames(3)
+    if err != nil || frame != 3 {
+        t.Fatalf("Expected frame 3, got=(%d, %v)", frame, err)
+    }
+
+    regs, err := c.Registers()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if regs.PC < 5 || regs.PC > 0x0c || regs.Cycles < 3*machine.CyclesPerFrame {
+        t.Errorf("Expected to be in the loop after 3 frames, got=%+v", regs)
+    }
+    mem, err := c.ReadMemory(0x2000, 2)
+    if err != nil {
+        t.Fatal(err)
+    }
+    // IN 1 has bit 3 always set and bit 0 for the coin slot.
+    if len(mem) != 2 || mem[0] != 0x42 || mem[1] != 0x09 {
+        t.Errorf("Expected 42 09, got=% X", mem)
+    }
+
+    state, err := c.SaveState()
+    if err != nil {
+        t.Fatal(err)
+    }
+    c.StepFrames(10)
+    if err := c.LoadState(state); err != nil {
+        t.Fatal(err)
+    }
+    if frame, _ := c.StepFrames(0); frame != 3 {
+        t.Errorf("Expected frame 3 after loading the state, got=%d", frame)
+    }
+    if err := c.LoadState([]byte("junk")); err == nil {
+        t.Errorf("Expected an error loading junk")
+    }
+
+    img, err := c.Screenshot()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if b := img.Bounds(); b.Dx() != machine.ScreenWidth || b.Dy() != machine.ScreenHeight {
+        t.Errorf("Expected a 224x256 screenshot, got=%v", b)
+    }
+
+    if err := c.Reset(); err != nil {
+        t.Fatal(err)
+    }
+    if regs, _ := c.Registers(); regs.PC != 0 || regs.Cycles != 0 {
+        t.Errorf("Expected a reset machine, got=%+v", regs)
+    }
+}
+
+// A bad ROM has to come back as an error, a panic in the server would take
+// the whole process down.
+func TestBadROM(t *testing.T) {
+    c := dialTest(t)
+    if err := c.LoadROM(make([]byte, 100)); err == nil {
+        t.Errorf("Expected a short ROM to be refused")
+    }
+    if err := c.LoadROM(nil); err == nil {
+        t.Errorf("Expected an empty ROM to be refused")
+    }
+    if err := c.LoadGame("nosuchgame", "../../roms"); err == nil {
+        t.Errorf("Expected an unknown game to be refused")
+    }
+    if err := c.call("LoadROM", remote.LoadROMArgs{Data: make([]byte, memory.Kilobytes(8)), Game: "invaders"},
+        &remote.Empty{}); err == nil || !strings.Contains(err.Error(), "bad dump") {
+        t.Errorf("Expected the invaders ROM set to be checked, got=%v", err)
+    }
+    if _, err := c.StepFrames(1); err == nil || err.Error() != remote.ErrNoROM.Error() {
+        t.Errorf("Expected the server to carry on without a ROM, got=%v", err)
+    }
+
+    if _, err := os.Stat("../../roms/invaders.rom"); err != nil {
+        t.Skip("no ROM: ", err)
+    }
+    if err := c.LoadGame("invaders", "../../roms"); err != nil {
+        t.Fatal(err)
+    }
+    if frame, err := c.StepFrames(2); err != nil || frame != 2 {
+        t.Errorf("Expected frame 2, got=(%d, %v)", frame, err)
+    }
+}
@@ -0,0 +1,286 @@
+// Package remote exposes a machine to other processes over JSON-RPC 1.0,
+// as net/rpc/jsonrpc speaks it, for test automation. The client package
+// next to it wraps the calls.
+//
+// Every method belongs to the "Emulator" service, e.g. "Emulator.StepFrames".
+package remote
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "image/png"
+    "net"
+    "net/rpc"
+    "net/rpc/jsonrpc"
+    "os"
+    "sync"
+
+    "github.com/siathema/goInvadeSpace/driver"
+    "github.com/siathema/goInvadeSpace/machine"
+)
+
+const ServiceName = "Emulator"
+
+var ErrNoROM = errors.New("remote: no ROM loaded")
+
+// Empty is the argument or reply of methods that don't need one.
+type Empty struct{}
+
+type LoadROMArgs struct {
+    // Path is read by the server, Data is used when Path is empty.
+    Path string
+    Data []byte
+    // Game, when set, is the driver whose ROM set the image is checked
+    // against, e.g. "invaders", and Path may be a directory or zip as
+    // romset.Set.Load takes them.
+    Game string
+}
+
+type StepArgs struct {
+    Frames int
+}
+
+type StepReply struct {
+    // Frames completed since the last reset.
+    Frame uint64
+}
+
+type InputArgs struct {
+    Input machine.Input
+}
+
+type Registers struct {
+    A, B, C, D, E, H, L, Flags uint8
+    SP, PC                     uint16
+    IntEnable, Halted          bool
+    Cycles                     uint64
+}
+
+type MemoryArgs struct {
+    Addr uint16
+    Len  int
+}
+
+// Service holds the machine the calls act on. Calls are serialized, so
+// several clients can share it.
+type Service struct {
+    mu   sync.Mutex
+    game *driver.Driver
+    rom  []uint8
+    m    *machine.Machine
+}
+
+// NewService serves m, which may be nil until a client loads a ROM. game
+// is the driver m was built with, nil for a bare machine.New.
+func NewService(game *driver.Driver, m *machine.Machine) *Service {
+    s := &Service{game: game, m: m}
+    if m != nil {
+        s.rom = m.ROM()
+    }
+
+    return s
+}
+
+// machine returns the machine with the lock held, the caller unlocks.
+func (s *Service) machine() (*machine.Machine, error) {
+    s.mu.Lock()
+    if s.m == nil {
+        s.mu.Unlock()
+        return nil, ErrNoROM
+    }
+
+    return s.m, nil
+}
+
+// LoadROM powers up a new machine with the ROM. Without a Game any image
+// of at least machine.ROMSize bytes is taken as it is.
+func (s *Service) LoadROM(args LoadROMArgs, _ *Empty) error {
+    var game *driver.Driver
+    var rom []uint8
+    var err error
+    if args.Game != "" {
+        if game, err = driver.Lookup(args.Game); err != nil {
+            return err
+        }
+    }
+    switch {
+    case game != nil && args.Path != "":
+        rom, err = game.ROMs.Load(args.Path)
+    case game != nil:
+        rom, err = game.ROMs.Split(args.Data)
+    case args.Path != "":
+        rom, err = os.ReadFile(args.Path)
+    default:
+        rom = args.Data
+    }
+    if err != nil {
+        return err
+    }
+    // Anything shorter would have the CPU read past the end of it.
+    if len(rom) < machine.ROMSize {
+        return fmt.Errorf("remote: ROM is %d bytes, want at least %d", len(rom), machine.ROMSize)
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.game, s.rom = game, rom
+    s.m = s.newMachine()
+
+    return nil
+}
+
+// newMachine powers up the loaded ROM, with mu held.
+func (s *Service) newMachine() *machine.Machine {
+    if s.game != nil {
+        return s.game.New(s.rom)
+    }
+
+    return machine.New(s.rom)
+}
+
+// Reset powers the machine off and on again, the inputs and dip switches
+// go back to their defaults.
+func (s *Service) Reset(_ Empty, _ *Empty) error {
+    _, err := s.machine()
+    if err != nil {
+        return err
+    }
+    defer s.mu.Unlock()
+    s.m = s.newMachine()
+
+    return nil
+}
+
+// StepFrames runs the machine for some frames with the inputs as last set.
+func (s *Service) StepFrames(args StepArgs, reply *StepReply) error {
+    m, err := s.machine()
+    if err != nil {
+        return err
+    }
+    defer s.mu.Unlock()
+    for i := 0; i < args.Frames; i++ {
+        m.RunFrame()
+    }
+    reply.Frame = m.Frame
+
+    return nil
+}
+
+// SetInput sets the buttons held from now on.
+func (s *Service) SetInput(args InputArgs, _ *Empty) error {
+    m, err := s.machine()
+    if err != nil {
+        return err
+    }
+    defer s.mu.Unlock()
+    m.Ports.Input = args.Input
+
+    return nil
+}
+
+func (s *Service) Registers(_ Empty, reply *Registers) error {
+    m, err := s.machine()
+    if err != nil {
+        return err
+    }
+    defer s.mu.Unlock()
+    c := m.CPU
+    *reply = Registers{
+        A: c.A, B: c.B, C: c.C, D: c.D, E: c.E, H: c.H, L: c.L, Flags: c.Flags,
+        SP: c.SP, PC: c.PC,
+        IntEnable: c.IntEnable, Halted: c.Halted,
+        Cycles: c.Cycles(),
+    }
+
+    return nil
+}
+
+// ReadMemory reads Len bytes from Addr on, wrapping at the top of the
+// address space like the CPU does.
+func (s *Service) ReadMemory(args MemoryArgs, reply *[]byte) error {
+    if args.Len < 0 || args.Len > 0x10000 {
+        return errors.New("remote: bad length")
+    }
+    m, err := s.machine()
+    if err != nil {
+        return err
+    }
+    defer s.mu.Unlock()
+    data := make([]byte, args.Len)
+    for i := range data {
+        data[i] = m.Mem.Read(args.Addr + uint16(i))
+    }
+    *reply = data
+
+    return nil
+}
+
+// Screenshot is the current frame as a PNG.
+func (s *Service) Screenshot(_ Empty, reply *[]byte) error {
+    m, err := s.machine()
+    if err != nil {
+        return err
+    }
+    img := m.Screen()
+    s.mu.Unlock()
+    var buf bytes.Buffer
+    if err := png.Encode(&buf, img); err != nil {
+        return err
+    }
+    *reply = buf.Bytes()
+
+    return nil
+}
+
+// SaveState returns a save state as machine.Save writes it.
+func (s *Service) SaveState(_ Empty, reply *[]byte) error {
+    m, err := s.machine()
+    if err != nil {
+        return err
+    }
+    defer s.mu.Unlock()
+    var buf bytes.Buffer
+    if err := m.Save(&buf); err != nil {
+        return err
+    }
+    *reply = buf.Bytes()
+
+    return nil
+}
+
+// LoadState restores a state from SaveState.
+func (s *Service) LoadState(state []byte, _ *Empty) error {
+    m, err := s.machine()
+    if err != nil {
+        return err
+    }
+    defer s.mu.Unlock()
+
+    return m.Load(bytes.NewReader(state))
+}
+
+// Serve answers JSON-RPC on every connection l accepts until it fails.
+func (s *Service) Serve(l net.Listener) error {
+    srv := rpc.NewServer()
+    if err := srv.RegisterName(ServiceName, s); err != nil {
+        return err
+    }
+    for {
+        conn, err := l.Accept()
+        if err != nil {
+            return err
+        }
+        go srv.ServeCodec(jsonrpc.NewServerCodec(conn))
+    }
+}
+
+// ListenAndServe serves on a "tcp" address or a "unix" socket path.
+func (s *Service) ListenAndServe(network, addr string) error {
+    l, err := net.Listen(network, addr)
+    if err != nil {
+        return err
+    }
+    defer l.Close()
+
+    return s.Serve(l)
+}
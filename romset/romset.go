@@ -0,0 +1,190 @@
+// Package romset loads a game's ROMs as MAME distributes them, one file
+// per chip, from a directory or a zip file, checking each chip against the
+// known good dump.
+package romset
+
+import (
+    "archive/zip"
+    "crypto/sha1"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// File is one chip of a set.
+type File struct {
+    Name   string
+    Offset int
+    Size   int
+    CRC32  uint32
+    SHA1   string
+}
+
+// Set is the ROMs of a game and where they go in its ROM image.
+type Set struct {
+    Name  string
+    Size  int
+    Files []File
+}
+
+// Invaders is MAME's "invaders", the Midway release.
+var Invaders = &Set{
+    Name: "invaders",
+    Size: 0x2000,
+    Files: []File{
+        {"invaders.h", 0x0000, 0x0800, 0x734f5ad8, "ff6200af4c9110d8181249cbcef1a8a40fa40b7f"},
+        {"invaders.g", 0x0800, 0x0800, 0x6bfaca4a, "16f48649b531bdef8c2d1446c429b5f414524350"},
+        {"invaders.f", 0x1000, 0x0800, 0x0ccead96, "537aef03468f63c5b9e11dd61e253f7ae17d9743"},
+        {"invaders.e", 0x1800, 0x0800, 0x14e538b0, "1d6ca0c99f9df71e2990b610deb9d7da0125e2d8"},
+    },
+}
+
+var (
+    ErrMissing = errors.New("missing")
+    ErrSize    = errors.New("wrong size")
+    ErrBadDump = errors.New("bad dump")
+)
+
+// FileError is a problem with one file of a set.
+type FileError struct {
+    Set  string
+    File string
+    Err  error
+    // Detail says what was found, e.g. the checksum that didn't match.
+    Detail string
+}
+
+func (e *FileError) Error() string {
+    s := fmt.Sprintf("romset %s: %s: %v", e.Set, e.File, e.Err)
+    if e.Detail != "" {
+        s += ", " + e.Detail
+    }
+    return s
+}
+
+func (e *FileError) Unwrap() error {
+    return e.Err
+}
+
+// Load builds the ROM image of set from path, which can be
+//
+//   - a directory holding the files, or a zip or whole image named after
+//     the set, like invaders.zip or invaders.rom
+//   - a zip of the files, matched by name in any folder and any case
+//   - a single file with the whole image, as older dumps come
+//
+// Every part is checked and the first problem returned.
+func (set *Set) Load(path string) ([]uint8, error) {
+    info, err := os.Stat(path)
+    if err != nil {
+        return nil, err
+    }
+    if info.IsDir() {
+        if _, err := os.Stat(filepath.Join(path, set.Files[0].Name)); err != nil {
+            for _, ext := range []string{".zip", ".rom"} {
+                alt := filepath.Join(path, set.Name+ext)
+                if _, err := os.Stat(alt); err == nil {
+                    return set.Load(alt)
+                }
+            }
+        }
+        return set.build(func(name string) ([]byte, error) {
+            return os.ReadFile(filepath.Join(path, name))
+        })
+    }
+    if strings.EqualFold(filepath.Ext(path), ".zip") {
+        return set.loadZip(path)
+    }
+
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    return set.Split(data)
+}
+
+func (set *Set) loadZip(path string) ([]uint8, error) {
+    z, err := zip.OpenReader(path)
+    if err != nil {
+        return nil, err
+    }
+    defer z.Close()
+    files := make(map[string]*zip.File)
+    for _, f := range z.File {
+        files[strings.ToLower(filepath.Base(f.Name))] = f
+    }
+
+    return set.build(func(name string) ([]byte, error) {
+        f, ok := files[strings.ToLower(name)]
+        if !ok {
+            return nil, os.ErrNotExist
+        }
+        r, err := f.Open()
+        if err != nil {
+            return nil, err
+        }
+        defer r.Close()
+        return io.ReadAll(r)
+    })
+}
+
+// Split checks a whole image, as Load would give it, part by part.
+func (set *Set) Split(image []uint8) ([]uint8, error) {
+    if len(image) != set.Size {
+        return nil, &FileError{Set: set.Name, File: "image", Err: ErrSize,
+            Detail: fmt.Sprintf("%d bytes, want %d", len(image), set.Size)}
+    }
+
+    return set.build(func(name string) ([]byte, error) {
+        for _, f := range set.Files {
+            if f.Name == name {
+                return image[f.Offset : f.Offset+f.Size], nil
+            }
+        }
+        return nil, os.ErrNotExist
+    })
+}
+
+func (set *Set) build(read func(name string) ([]byte, error)) ([]uint8, error) {
+    image := make([]uint8, set.Size)
+    for _, f := range set.Files {
+        data, err := read(f.Name)
+        if errors.Is(err, os.ErrNotExist) {
+            return nil, &FileError{Set: set.Name, File: f.Name, Err: ErrMissing}
+        } else if err != nil {
+            return nil, err
+        }
+        if err := f.Verify(data); err != nil {
+            ferr := err.(*FileError)
+            ferr.Set = set.Name
+            return nil, ferr
+        }
+        copy(image[f.Offset:], data)
+    }
+
+    return image, nil
+}
+
+// Verify checks data against the known good dump.
+func (f File) Verify(data []byte) error {
+    if len(data) != f.Size {
+        return &FileError{File: f.Name, Err: ErrSize,
+            Detail: fmt.Sprintf("%d bytes, want %d", len(data), f.Size)}
+    }
+    if sum := crc32.ChecksumIEEE(data); sum != f.CRC32 {
+        return &FileError{File: f.Name, Err: ErrBadDump,
+            Detail: fmt.Sprintf("CRC32 %08x, want %08x", sum, f.CRC32)}
+    }
+    sum := sha1.Sum(data)
+    if got := hex.EncodeToString(sum[:]); got != f.SHA1 {
+        return &FileError{File: f.Name, Err: ErrBadDump,
+            Detail: fmt.Sprintf("SHA1 %s, want %s", got, f.SHA1)}
+    }
+
+    return nil
+}
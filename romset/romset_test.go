@@ -0,0 +1,102 @@
+package romset
+
+import (
+    "archive/zip"
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func loadImage(t *testing.T) []uint8 {
+    image, err := os.ReadFile("../roms/invaders.rom")
+    if err != nil {
+        t.Skip("no ROM: ", err)
+    }
+    return image
+}
+
+func writeZip(t *testing.T, path string, files map[string][]byte) {
+    f, err := os.Create(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+    z := zip.NewWriter(f)
+    for name, data := range files {
+        w, err := z.Create(name)
+        if err != nil {
+            t.Fatal(err)
+        }
+        w.Write(data)
+    }
+    if err := z.Close(); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestLoad(t *testing.T) {
+    image := loadImage(t)
+    parts := map[string][]byte{}
+    for _, f := range Invaders.Files {
+        parts[f.Name] = image[f.Offset : f.Offset+f.Size]
+    }
+
+    dir := t.TempDir()
+    for name, data := range parts {
+        os.WriteFile(filepath.Join(dir, name), data, 0644)
+    }
+    zipDir := t.TempDir()
+    zipped := map[string][]byte{}
+    for name, data := range parts {
+        zipped["Invaders/"+strings.ToUpper(name)] = data
+    }
+    writeZip(t, filepath.Join(zipDir, "invaders.zip"), zipped)
+
+    for _, path := range []string{
+        dir,
+        zipDir,
+        filepath.Join(zipDir, "invaders.zip"),
+        "../roms/invaders.rom",
+        "../roms",
+    } {
+        got, err := Invaders.Load(path)
+        if err != nil {
+            t.Errorf("%s: %v", path, err)
+            continue
+        }
+        if string(got) != string(image) {
+            t.Errorf("%s: Expected the original image", path)
+        }
+    }
+}
+
+func TestLoadErrors(t *testing.T) {
+    image := loadImage(t)
+    dir := t.TempDir()
+    for _, f := range Invaders.Files[:3] {
+        os.WriteFile(filepath.Join(dir, f.Name), image[f.Offset:f.Offset+f.Size], 0644)
+    }
+    _, err := Invaders.Load(dir)
+    var ferr *FileError
+    if !errors.As(err, &ferr) || ferr.File != "invaders.e" || !errors.Is(err, ErrMissing) {
+        t.Errorf("Expected invaders.e missing, got=%v", err)
+    }
+
+    bad := append([]uint8(nil), image[0x1800:]...)
+    bad[10] ^= 0xff
+    os.WriteFile(filepath.Join(dir, "invaders.e"), bad, 0644)
+    _, err = Invaders.Load(dir)
+    if !errors.Is(err, ErrBadDump) || !strings.Contains(err.Error(), "want 14e538b0") {
+        t.Errorf("Expected a bad dump of invaders.e, got=%v", err)
+    }
+
+    os.WriteFile(filepath.Join(dir, "invaders.e"), bad[:100], 0644)
+    if _, err = Invaders.Load(dir); !errors.Is(err, ErrSize) {
+        t.Errorf("Expected a wrong size, got=%v", err)
+    }
+    if _, err = Invaders.Split(image[:0x1000]); !errors.Is(err, ErrSize) {
+        t.Errorf("Expected a wrong image size, got=%v", err)
+    }
+}
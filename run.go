@@ -0,0 +1,206 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "os/signal"
+    "strings"
+    "syscall"
+    "time"
+
+    "github.com/siathema/goInvadeSpace/machine"
+    "github.com/siathema/goInvadeSpace/remote"
+    "github.com/siathema/goInvadeSpace/term"
+    "github.com/siathema/goInvadeSpace/web"
+)
+
+func runCmd(args []string) error {
+    fs := newFlagSet("run", "")
+    mf := addMachineFlags(fs)
+    frames := fs.Int("frames", 0, "headless: stop after this many frames, 0 runs until interrupted")
+    speed := fs.Float64("speed", 1, "speed relative to the real machine, 0 for as fast as possible")
+    recordFile := fs.String("record", "", "headless: record the inputs to this movie file, written at the end")
+    playFile := fs.String("play", "", "headless: play back this movie file, ending with it")
+    termMode := fs.String("term", "", "play in the terminal, drawn in braille or half (half blocks)")
+    noColor := fs.Bool("no-color", false, "draw the terminal without the colour overlay")
+    serveAddr := fs.String("serve", "", "serve the game over HTTP on this address, e.g. localhost:8080")
+    rpcAddr := fs.String("rpc", "", "serve the JSON-RPC control API on this address, host:port or a unix socket path")
+    if err := parse(fs, args); err != nil {
+        return err
+    }
+    if fs.NArg() != 0 {
+        return usageError("run takes no arguments")
+    }
+    if *speed < 0 {
+        return usageError("-speed can't be negative")
+    }
+    frontends := 0
+    for _, s := range []string{*termMode, *serveAddr, *rpcAddr} {
+        if s != "" {
+            frontends++
+        }
+    }
+    if frontends > 1 {
+        return usageError("pick one of -term, -serve and -rpc")
+    }
+    if frontends == 1 && (*frames != 0 || *recordFile != "" || *playFile != "") {
+        return usageError("-frames, -record and -play are for headless runs")
+    }
+    // A movie replays with the dip switches it was recorded with.
+    if *playFile != "" {
+        for _, name := range []string{"ships", "early-bonus", "no-coin-info"} {
+            if flagSet(fs, name) {
+                return usageError(fmt.Sprintf("-%s can't be used with -play, the movie has its own dip switches", name))
+            }
+        }
+    }
+    if *rpcAddr != "" && flagSet(fs, "speed") {
+        return usageError("-speed is not for -rpc, its clients step the machine")
+    }
+
+    game, rom, err := mf.load()
+    if err != nil {
+        return err
+    }
+    m := game.New(rom)
+    var play []machine.Input
+    if *playFile != "" {
+        mv, err := readMovie(*playFile)
+        if err != nil {
+            return err
+        }
+        // The movie has its own dip switches.
+        if m, err = mv.Machine(rom); err != nil {
+            return err
+        }
+        if game.Inputs != nil {
+            m.Ports.Layout = game.Inputs
+        }
+        play = mv.Inputs
+    } else if err := mf.setDIPs(m); err != nil {
+        return err
+    }
+    stopTrace, err := mf.startTrace(game, m)
+    if err != nil {
+        return err
+    }
+    defer stopTrace()
+
+    switch {
+    case *rpcAddr != "":
+        network := "tcp"
+        if strings.Contains(*rpcAddr, "/") {
+            network = "unix"
+        }
+        fmt.Fprintf(os.Stderr, "serving JSON-RPC on %s %s\n", network, *rpcAddr)
+        return remote.NewService(game, m).ListenAndServe(network, *rpcAddr)
+    case *serveAddr != "":
+        fmt.Fprintf(os.Stderr, "serving on http://%s/\n", *serveAddr)
+        s := web.New(m)
+        s.Speed = *speed
+        return s.ListenAndServe(*serveAddr)
+    case *termMode != "":
+        f := term.New()
+        switch *termMode {
+        case "braille":
+            f.Mode = term.Braille
+        case "half":
+            f.Mode = term.HalfBlock
+        default:
+            return usageError(fmt.Sprintf("unknown terminal mode %q, want braille or half", *termMode))
+        }
+        f.Color = !*noColor
+        f.Speed = *speed
+        return f.Run(m)
+    }
+
+    var rec *machine.Movie
+    if *recordFile != "" {
+        if rec, err = machine.RecordMovie(m); err != nil {
+            return err
+        }
+    }
+    var tick <-chan time.Time
+    if *speed > 0 {
+        t := time.NewTicker(time.Duration(float64(time.Second) / machine.FrameRate / *speed))
+        defer t.Stop()
+        tick = t.C
+    }
+    // An interrupt ends the run like -frames would, so the movie and trace
+    // are still written.
+    stop := make(chan os.Signal, 1)
+    signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+    defer signal.Stop(stop)
+    start := time.Now()
+run:
+    for *frames == 0 || m.Frame < uint64(*frames) {
+        select {
+        case <-stop:
+            break run
+        default:
+        }
+        if *playFile != "" {
+            if len(play) == 0 {
+                break
+            }
+            m.Ports.Input, play = play[0], play[1:]
+        }
+        if rec != nil {
+            rec.RunFrame(m)
+        } else {
+            m.RunFrame()
+        }
+        if tick != nil {
+            select {
+            case <-stop:
+                break run
+            case <-tick:
+            }
+        }
+    }
+    fmt.Fprintf(os.Stderr, "ran %d frames in %v\n", m.Frame, time.Since(start).Round(time.Millisecond))
+
+    if rec != nil {
+        if err := writeMovie(*recordFile, rec); err != nil {
+            return err
+        }
+    }
+
+    return stopTrace()
+}
+
+// flagSet says whether name was given on the command line.
+func flagSet(fs *flag.FlagSet, name string) bool {
+    set := false
+    fs.Visit(func(f *flag.Flag) {
+        if f.Name == name {
+            set = true
+        }
+    })
+
+    return set
+}
+
+func readMovie(name string) (*machine.Movie, error) {
+    f, err := os.Open(name)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+
+    return machine.ReadMovie(f)
+}
+
+func writeMovie(name string, mv *machine.Movie) error {
+    f, err := os.Create(name)
+    if err != nil {
+        return err
+    }
+    if err := mv.Write(f); err != nil {
+        f.Close()
+        return err
+    }
+
+    return f.Close()
+}
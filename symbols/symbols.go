@@ -0,0 +1,187 @@
+package symbols
+
+import (
+    "bufio"
+    _ "embed"
+    "fmt"
+    "io"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+//go:embed invaders.sym
+var invadersSym string
+
+// Table maps addresses to names and back.
+type Table struct {
+    byAddr map[uint16]string
+    byName map[string]uint16
+    sorted []uint16
+}
+
+func New() *Table {
+    t := &Table{
+        byAddr: make(map[uint16]string),
+        byName: make(map[string]uint16),
+    }
+
+    return t
+}
+
+// Invaders returns the built in names for the Space Invaders ROM and RAM.
+func Invaders() *Table {
+    t, err := Parse(strings.NewReader(invadersSym))
+    if err != nil {
+        panic(err)
+    }
+
+    return t
+}
+
+func Load(path string) (*Table, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+
+    t, err := Parse(f)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+
+    return t, nil
+}
+
+// Parse reads one symbol per line in either of these forms, everything
+// after a ';' being a comment:
+//
+//    1A32 BlockCopy
+//    BlockCopy EQU 1A32H
+//
+// The second is what assemblers print in their symbol table listing, the
+// colon after the name and '=' in place of EQU are accepted too. Values are
+// hex with an optional 0x, $ or H marker.
+func Parse(r io.Reader) (*Table, error) {
+    t := New()
+    scanner := bufio.NewScanner(r)
+    line := 0
+    for scanner.Scan() {
+        line++
+        text, _, _ := strings.Cut(scanner.Text(), ";")
+        fields := strings.Fields(text)
+        if len(fields) == 0 {
+            continue
+        }
+
+        var name, value string
+        switch {
+        case len(fields) == 2:
+            value, name = fields[0], fields[1]
+        case len(fields) == 3 && (strings.EqualFold(fields[1], "EQU") || fields[1] == "="):
+            name, value = fields[0], fields[2]
+        default:
+            return nil, fmt.Errorf("line %d: expected \"ADDR NAME\" or \"NAME EQU ADDR\"", line)
+        }
+        name = strings.TrimSuffix(name, ":")
+        addr, err := parseAddr(value)
+        if err != nil {
+            return nil, fmt.Errorf("line %d: %v", line, err)
+        }
+        t.Add(addr, name)
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+
+    return t, nil
+}
+
+func parseAddr(s string) (uint16, error) {
+    t := strings.ToLower(s)
+    t = strings.TrimPrefix(t, "0x")
+    t = strings.TrimPrefix(t, "$")
+    t = strings.TrimSuffix(t, "h")
+    v, err := strconv.ParseUint(t, 16, 16)
+    if err != nil {
+        return 0, fmt.Errorf("bad address %q", s)
+    }
+
+    return uint16(v), nil
+}
+
+// Add names addr. A second name for the same address replaces the first.
+func (t *Table) Add(addr uint16, name string) {
+    if old, ok := t.byAddr[addr]; ok {
+        delete(t.byName, old)
+    } else {
+        i := sort.Search(len(t.sorted), func(i int) bool { return t.sorted[i] >= addr })
+        t.sorted = append(t.sorted, 0)
+        copy(t.sorted[i+1:], t.sorted[i:])
+        t.sorted[i] = addr
+    }
+    t.byAddr[addr] = name
+    t.byName[name] = addr
+}
+
+// Merge copies every symbol of other into t.
+func (t *Table) Merge(other *Table) {
+    for _, addr := range other.sorted {
+        t.Add(addr, other.byAddr[addr])
+    }
+}
+
+func (t *Table) Len() int {
+    return len(t.sorted)
+}
+
+// Symbol returns the name at exactly addr. It makes *Table a
+// core.Symbolizer.
+func (t *Table) Symbol(addr uint16) (string, bool) {
+    if t == nil {
+        return "", false
+    }
+    name, ok := t.byAddr[addr]
+
+    return name, ok
+}
+
+func (t *Table) Addr(name string) (uint16, bool) {
+    if t == nil {
+        return 0, false
+    }
+    addr, ok := t.byName[name]
+
+    return addr, ok
+}
+
+// Nearest returns the closest symbol at or below addr and the distance to
+// it, so a PC inside a routine can be shown as "DrawAlien+12".
+func (t *Table) Nearest(addr uint16) (string, uint16, bool) {
+    if t == nil || len(t.sorted) == 0 {
+        return "", 0, false
+    }
+    i := sort.Search(len(t.sorted), func(i int) bool { return t.sorted[i] > addr })
+    if i == 0 {
+        return "", 0, false
+    }
+    base := t.sorted[i-1]
+
+    return t.byAddr[base], addr - base, true
+}
+
+// Format renders addr as "Name", "Name+off" or plain hex when nothing is
+// at or below it.
+func (t *Table) Format(addr uint16) string {
+    name, off, ok := t.Nearest(addr)
+    switch {
+    case !ok:
+        return fmt.Sprintf("%04X", addr)
+    case off == 0:
+        return name
+    }
+
+    return fmt.Sprintf("%s+%d", name, off)
+}
@@ -0,0 +1,77 @@
+package symbols
+
+import (
+    "strings"
+    "testing"
+
+    "github.com/siathema/goInvadeSpace/core"
+)
+
+func TestParse(t *testing.T) {
+    input := `; comment
+1A32 BlockCopy
+0x1A5C ClearScreen ; trailing comment
+
+DrawAlien:  EQU  0100H
+numCoins = $20EB
+`
+    table, err := Parse(strings.NewReader(input))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        name string
+        addr uint16
+    }{
+        {"BlockCopy", 0x1A32},
+        {"ClearScreen", 0x1A5C},
+        {"DrawAlien", 0x0100},
+        {"numCoins", 0x20EB},
+    }
+    for _, tt := range tests {
+        if addr, ok := table.Addr(tt.name); !ok || addr != tt.addr {
+            t.Errorf("Expected %s=%04X, got=(%04X, %v)", tt.name, tt.addr, addr, ok)
+        }
+        if name, ok := table.Symbol(tt.addr); !ok || name != tt.name {
+            t.Errorf("Expected %04X=%s, got=(%q, %v)", tt.addr, tt.name, name, ok)
+        }
+    }
+
+    if _, err := Parse(strings.NewReader("1A32 Block Copy Routine\n")); err == nil {
+        t.Errorf("Expected an error for a malformed line")
+    }
+}
+
+func TestFormat(t *testing.T) {
+    table := New()
+    table.Add(0x0100, "DrawAlien")
+    table.Add(0x1A32, "BlockCopy")
+
+    tests := []struct {
+        addr     uint16
+        expected string
+    }{
+        {0x0100, "DrawAlien"},
+        {0x010C, "DrawAlien+12"},
+        {0x1A33, "BlockCopy+1"},
+        {0x00FF, "00FF"},
+    }
+    for _, tt := range tests {
+        if s := table.Format(tt.addr); s != tt.expected {
+            t.Errorf("Expected %q, got=%q", tt.expected, s)
+        }
+    }
+}
+
+func TestInvaders(t *testing.T) {
+    table := Invaders()
+    // CALL $0100 in the ROM should come out named.
+    text, _ := core.DisassembleSym([]uint8{0xcd, 0x00, 0x01}, table)
+    if text != "CALL DrawAlien" {
+        t.Errorf("Expected \"CALL DrawAlien\", got=%q", text)
+    }
+    if addr, ok := table.Addr("init"); !ok || addr != 0x18D4 {
+        t.Errorf("Expected init=18D4, got=(%04X, %v)", addr, ok)
+    }
+}
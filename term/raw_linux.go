@@ -0,0 +1,10 @@
+//go:build linux
+
+package term
+
+import "syscall"
+
+const (
+    ioctlGet = syscall.TCGETS
+    ioctlSet = syscall.TCSETS
+)
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package term
+
+import "errors"
+
+func makeRaw(fd int) (func(), error) {
+    return nil, errors.New("term: raw mode isn't supported on this system")
+}
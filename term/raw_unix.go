@@ -0,0 +1,38 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package term
+
+import (
+    "syscall"
+    "unsafe"
+)
+
+// makeRaw turns off line buffering, echo and signal keys on the terminal
+// fd, like cfmakeraw without touching output processing, and returns a
+// function putting it back. Reads give up after a tenth of a second with
+// nothing, returning io.EOF, so the one reading keys can be stopped.
+func makeRaw(fd int) (func(), error) {
+    var old syscall.Termios
+    if err := ioctl(fd, ioctlGet, &old); err != nil {
+        return nil, err
+    }
+    raw := old
+    raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+        syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+    raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+    raw.Cc[syscall.VMIN] = 0
+    raw.Cc[syscall.VTIME] = 1
+    if err := ioctl(fd, ioctlSet, &raw); err != nil {
+        return nil, err
+    }
+
+    return func() { ioctl(fd, ioctlSet, &old) }, nil
+}
+
+func ioctl(fd int, req uintptr, t *syscall.Termios) error {
+    _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(unsafe.Pointer(t)))
+    if errno != 0 {
+        return errno
+    }
+    return nil
+}
@@ -0,0 +1,116 @@
+// Package term plays the game in a text terminal, drawing the screen with
+// Unicode block or braille characters and reading the keyboard in raw mode.
+package term
+
+import (
+    "unicode/utf8"
+
+    "github.com/siathema/goInvadeSpace/machine"
+)
+
+type Mode int
+
+const (
+    // Braille packs 2x4 pixels into a character, 112 columns by 64 rows.
+    Braille Mode = iota
+    // HalfBlock packs 1x2 pixels, 224 columns by 128 rows, for fonts
+    // without braille.
+    HalfBlock
+)
+
+// Cell size of each mode in pixels.
+func (mode Mode) cell() (int, int) {
+    if mode == HalfBlock {
+        return 1, 2
+    }
+    return 2, 4
+}
+
+// Size is the number of columns and rows a frame takes up.
+func (mode Mode) Size() (int, int) {
+    w, h := mode.cell()
+    return machine.ScreenWidth / w, machine.ScreenHeight / h
+}
+
+// ANSI colours of the strips of cellophane on the cabinet's screen.
+const (
+    white = "\x1b[97m"
+    red   = "\x1b[91m"
+    green = "\x1b[92m"
+    reset = "\x1b[0m"
+)
+
+// overlay is the colour of x, y of the upright screen: red across the top
+// where the saucer flies, green over the bases, the player and the ships
+// left at the bottom, white elsewhere.
+func overlay(x, y int) string {
+    switch {
+    case y >= 32 && y < 64:
+        return red
+    case y >= 184 && y < 240:
+        return green
+    case y >= 240 && x >= 16 && x < 134:
+        return green
+    }
+    return white
+}
+
+// braille dot bits, indexed by the row then the column in the cell.
+var brailleDots = [4][2]rune{
+    {0x01, 0x08},
+    {0x02, 0x10},
+    {0x04, 0x20},
+    {0x40, 0x80},
+}
+
+// Frame appends vram drawn in mode to buf, one line per row of characters
+// ending in "\r\n" so it works in raw mode. With color set the overlay is
+// drawn in ANSI colours.
+func Frame(buf []byte, vram []uint8, mode Mode, color bool) []byte {
+    cw, ch := mode.cell()
+    cols, rows := mode.Size()
+    for row := 0; row < rows; row++ {
+        last := ""
+        for col := 0; col < cols; col++ {
+            x, y := col*cw, row*ch
+            if color {
+                if c := overlay(x, y); c != last {
+                    buf = append(buf, c...)
+                    last = c
+                }
+            }
+            buf = utf8.AppendRune(buf, cellRune(vram, mode, x, y))
+        }
+        if color {
+            buf = append(buf, reset...)
+        }
+        buf = append(buf, '\r', '\n')
+    }
+
+    return buf
+}
+
+func cellRune(vram []uint8, mode Mode, x, y int) rune {
+    if mode == HalfBlock {
+        top, bottom := machine.Pixel(vram, x, y), machine.Pixel(vram, x, y+1)
+        switch {
+        case top && bottom:
+            return '█'
+        case top:
+            return '▀'
+        case bottom:
+            return '▄'
+        }
+        return ' '
+    }
+    r := rune(0x2800)
+    for dy := 0; dy < 4; dy++ {
+        for dx := 0; dx < 2; dx++ {
+            if machine.Pixel(vram, x+dx, y+dy) {
+                r |= brailleDots[dy][dx]
+            }
+        }
+    }
+
+    return r
+}
@@ -0,0 +1,247 @@
+package term
+
+import (
+    "io"
+    "os"
+    "time"
+
+    "github.com/siathema/goInvadeSpace/machine"
+)
+
+// Terminals only report key presses, not releases, so a key holds its
+// input down for a while, and keyboard auto repeat keeps it down.
+const DefaultHold = 8
+
+// Frontend runs a machine in the terminal, at 60 frames a second unless
+// Speed says otherwise.
+//
+// Keys: c coin, 1 and 2 start, space fire, left and right arrows (or a and
+// d) move, t tilt, r rewind, q or ctrl-c quit.
+type Frontend struct {
+    Mode  Mode
+    Color bool
+    // Frames an input stays pressed after its key.
+    Hold int
+    // Speed relative to the real machine, 0 for as fast as possible.
+    Speed float64
+    // Rewind records play for r to step back through, nil turns it off.
+    Rewind *machine.Rewind
+    In    *os.File
+    Out  io.Writer
+}
+
+func New() *Frontend {
+    return &Frontend{
+        Mode:   Braille,
+        Color:  true,
+        Hold:   DefaultHold,
+        Speed:  1,
+        Rewind: machine.NewRewind(machine.RewindInterval, machine.RewindBudget),
+        In:     os.Stdin,
+        Out:    os.Stdout,
+    }
+}
+
+// Run plays m until quit, restoring the terminal on the way out.
+func (f *Frontend) Run(m *machine.Machine) error {
+    restore, err := makeRaw(int(f.In.Fd()))
+    if err != nil {
+        return err
+    }
+    defer restore()
+    // Hide the cursor and clear, then show it again after.
+    io.WriteString(f.Out, "\x1b[?25l\x1b[2J")
+    defer io.WriteString(f.Out, "\x1b[?25h"+reset+"\r\n")
+
+    keys := readKeys(f.In)
+    defer keys.stop()
+
+    var held [16]int
+    var buf []byte
+    var tick <-chan time.Time
+    if f.Speed > 0 {
+        t := time.NewTicker(time.Duration(float64(time.Second) / machine.FrameRate / f.Speed))
+        defer t.Stop()
+        tick = t.C
+    }
+    for {
+    drain:
+        for {
+            select {
+            case b, ok := <-keys.c:
+                if !ok {
+                    return nil
+                }
+                in, quit := decodeKeys(b)
+                if quit {
+                    return nil
+                }
+                for bit := range held {
+                    if in&(1<<bit) != 0 {
+                        held[bit] = f.Hold
+                    }
+                }
+            default:
+                break drain
+            }
+        }
+
+        var in machine.Input
+        for bit := range held {
+            if held[bit] > 0 {
+                in |= 1 << bit
+                held[bit]--
+            }
+        }
+        f.step(m, in)
+
+        buf = append(buf[:0], "\x1b[H"...)
+        buf = Frame(buf, m.VRAM(), f.Mode, f.Color)
+        if _, err := f.Out.Write(buf); err != nil {
+            return err
+        }
+        if tick != nil {
+            <-tick
+        }
+    }
+}
+
+// step runs a frame with in held, or while r is held steps back through
+// Rewind instead.
+func (f *Frontend) step(m *machine.Machine, in machine.Input) {
+    if in&rewindKey != 0 && f.Rewind != nil {
+        f.Rewind.Back(m)
+        return
+    }
+    m.Ports.Input = in &^ rewindKey
+    m.RunFrame()
+    if f.Rewind != nil {
+        f.Rewind.Capture(m)
+    }
+}
+
+// keyReader reads the keyboard on a goroutine of its own, so frames never
+// wait for a key. The terminal has to be in the raw mode makeRaw sets, where
+// io.EOF means no key came in time rather than the end.
+type keyReader struct {
+    in   *os.File
+    c    chan []byte
+    done chan struct{}
+    // closed when the goroutine has finished
+    exited chan struct{}
+}
+
+func readKeys(in *os.File) *keyReader {
+    r := &keyReader{
+        in:     in,
+        c:      make(chan []byte),
+        done:   make(chan struct{}),
+        exited: make(chan struct{}),
+    }
+    go r.run()
+
+    return r
+}
+
+func (r *keyReader) run() {
+    defer close(r.exited)
+    defer close(r.c)
+    for {
+        b := make([]byte, 64)
+        n, err := r.in.Read(b)
+        if n > 0 {
+            select {
+            case r.c <- b[:n]:
+            case <-r.done:
+                return
+            }
+        }
+        select {
+        case <-r.done:
+            return
+        default:
+        }
+        if err != nil && err != io.EOF {
+            return
+        }
+    }
+}
+
+// stop ends the goroutine and waits for it, at most the tenth of a second a
+// raw mode read takes to give up. Files taking deadlines have theirs set to
+// cut a read short, and cleared again after.
+func (r *keyReader) stop() {
+    close(r.done)
+    deadline := r.in.SetReadDeadline(time.Now()) == nil
+    <-r.exited
+    if deadline {
+        r.in.SetReadDeadline(time.Time{})
+    }
+}
+
+// rewindKey is r, on a bit machine.Input doesn't use so it's held like
+// the controls.
+const rewindKey machine.Input = 1 << 15
+
+var keyInputs = map[byte]machine.Input{
+    'c': machine.Coin,
+    '1': machine.P1Start,
+    '2': machine.P2Start,
+    ' ': machine.P1Fire,
+    'a': machine.P1Left,
+    'd': machine.P1Right,
+    't': machine.Tilt,
+    'r': rewindKey,
+}
+
+// decodeKeys turns what one read of the keyboard gave into the inputs it
+// presses, or reports that the player quit. Letters count in either case.
+func decodeKeys(b []byte) (machine.Input, bool) {
+    var in machine.Input
+    for len(b) > 0 {
+        if b[0] == 0x1b {
+            var final byte
+            final, b = escape(b)
+            // Left and right arrows, whatever modifiers they carry.
+            switch final {
+            case 'D':
+                in |= machine.P1Left
+            case 'C':
+                in |= machine.P1Right
+            }
+            continue
+        }
+        key := b[0]
+        b = b[1:]
+        if key >= 'A' && key <= 'Z' {
+            key += 'a' - 'A'
+        }
+        if key == 'q' || key == 0x03 {
+            return in, true
+        }
+        in |= keyInputs[key]
+    }
+
+    return in, false
+}
+
+// escape splits an escape sequence off b, returning its final byte. Only
+// CSI ("ESC [") and SS3 ("ESC O"), which cursor keys send, have one, any
+// other ESC is dropped on its own and gives 0.
+func escape(b []byte) (byte, []byte) {
+    if len(b) < 2 || (b[1] != '[' && b[1] != 'O') {
+        return 0, b[1:]
+    }
+    // Parameter and intermediate bytes, then the final byte.
+    for i := 2; i < len(b); i++ {
+        if b[i] >= 0x40 && b[i] <= 0x7e {
+            return b[i], b[i+1:]
+        }
+        if b[i] < 0x20 || b[i] > 0x3f {
+            // Not a sequence after all.
+            return 0, b[i:]
+        }
+    }
+
+    return 0, nil
+}
@@ -0,0 +1,129 @@
+package term
+
+import (
+    "os"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/siathema/goInvadeSpace/machine"
+)
+
+// setPixel lights x, y of the upright screen.
+func setPixel(vram []uint8, x, y int) {
+    row := machine.ScreenHeight - 1 - y
+    vram[x*32+row/8] |= 1 << (row % 8)
+}
+
+func TestFrame(t *testing.T) {
+    vram := make([]uint8, machine.VRAMSize)
+    setPixel(vram, 0, 0)
+    setPixel(vram, 1, 3)
+    setPixel(vram, 223, 255)
+
+    lines := strings.Split(string(Frame(nil, vram, Braille, false)), "\r\n")
+    if len(lines) != 65 || lines[64] != "" {
+        t.Fatalf("Expected 64 lines, got=%d", len(lines)-1)
+    }
+    first := []rune(lines[0])
+    if len(first) != 112 || first[0] != '⢁' || first[1] != '⠀' {
+        t.Errorf("Expected a 112 wide first line starting with dots 1 and 8, got=%q", lines[0][:6])
+    }
+    if last := []rune(lines[63]); last[111] != '⢀' {
+        t.Errorf("Expected dot 8 bottom right, got=%q", last[111])
+    }
+
+    lines = strings.Split(string(Frame(nil, vram, HalfBlock, false)), "\r\n")
+    if len(lines) != 129 || !strings.HasPrefix(lines[0], "▀ ") || !strings.HasPrefix(lines[1], " ▄") {
+        t.Errorf("Expected half blocks, got=%q %q", lines[0][:4], lines[1][:4])
+    }
+
+    // Colour changes only where the overlay does.
+    colored := string(Frame(nil, vram, Braille, true))
+    if n := strings.Count(colored, red); n != 8 {
+        t.Errorf("Expected 8 red rows, got=%d", n)
+    }
+}
+
+func TestDecodeKeys(t *testing.T) {
+    tests := []struct {
+        keys string
+        in   machine.Input
+        quit bool
+    }{
+        {"c", machine.Coin, false},
+        {"1 ", machine.P1Start | machine.P1Fire, false},
+        {"\x1b[D\x1b[C", machine.P1Left | machine.P1Right, false},
+        {"D", machine.P1Right, false},
+        {"xq", 0, true},
+        {"\x03", 0, true},
+        // Up and down aren't a and b, other sequences are dropped whole.
+        {"\x1b[A\x1b[B", 0, false},
+        {"\x1bOD\x1b[1;2C", machine.P1Left | machine.P1Right, false},
+        {"\x1b[15~c", machine.Coin, false},
+        {"\x1bc", machine.Coin, false},
+        {"\x00\r", 0, false},
+        {"Q", 0, true},
+        {"r", rewindKey, false},
+    }
+    for _, tt := range tests {
+        in, quit := decodeKeys([]byte(tt.keys))
+        if in != tt.in || quit != tt.quit {
+            t.Errorf("%q: Expected (%v, %v), got=(%v, %v)", tt.keys, tt.in, tt.quit, in, quit)
+        }
+    }
+}
+
+func TestKeyReaderStops(t *testing.T) {
+    // A pipe stands in for the terminal, it takes deadlines where a
+    // terminal's reads time out by themselves.
+    r, w, err := os.Pipe()
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer r.Close()
+    defer w.Close()
+
+    keys := readKeys(r)
+    w.Write([]byte("c"))
+    if b := <-keys.c; string(b) != "c" {
+        t.Fatalf("Expected c, got=%q", b)
+    }
+    stopped := make(chan struct{})
+    go func() {
+        keys.stop()
+        close(stopped)
+    }()
+    select {
+    case <-stopped:
+    case <-time.After(5 * time.Second):
+        t.Fatal("Expected stop to end a blocked read")
+    }
+
+    // The next reader gets the keys again.
+    w.Write([]byte("x"))
+    b := make([]byte, 1)
+    if _, err := r.Read(b); err != nil || b[0] != 'x' {
+        t.Errorf("Expected x after the reader stopped, got=(%q, %v)", b, err)
+    }
+}
+
+func TestRewindKey(t *testing.T) {
+    f := New()
+    m := machine.New(make([]uint8, 0x2000))
+    for i := 0; i < 20; i++ {
+        f.step(m, 0)
+    }
+    f.step(m, rewindKey)
+    if m.Frame != 20 {
+        t.Errorf("Expected r to go back to the capture at frame 20, got=%d", m.Frame)
+    }
+    f.step(m, rewindKey)
+    if m.Frame != 16 {
+        t.Errorf("Expected r held to keep going back, got=%d", m.Frame)
+    }
+    f.step(m, machine.Coin)
+    if m.Frame != 17 || m.Ports.Input != machine.Coin {
+        t.Errorf("Expected play to carry on from there, got frame %d", m.Frame)
+    }
+}
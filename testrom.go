@@ -0,0 +1,57 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "os"
+    "strings"
+    "time"
+
+    "github.com/siathema/goInvadeSpace/cpm"
+)
+
+func testROMCmd(args []string) error {
+    fs := newFlagSet("test-rom", "FILE...")
+    limit := fs.Uint64("limit", 0, "fail a program still running after this many instructions, 0 for no limit")
+    quiet := fs.Bool("q", false, "only report pass or fail, not the programs' output")
+    if err := parse(fs, args); err != nil {
+        return err
+    }
+    if fs.NArg() == 0 {
+        return usageError("no test programs given, e.g. TST8080.COM")
+    }
+
+    failed := 0
+    for _, path := range fs.Args() {
+        var out strings.Builder
+        var w io.Writer = io.MultiWriter(&out, os.Stdout)
+        if *quiet {
+            w = &out
+        }
+        m, err := cpm.Load(path, w)
+        if err != nil {
+            return err
+        }
+        start := time.Now()
+        err = m.Run(*limit)
+        took := time.Since(start).Round(time.Millisecond)
+        if !*quiet && !strings.HasSuffix(out.String(), "\n") {
+            fmt.Println()
+        }
+        switch {
+        case err != nil:
+            fmt.Printf("FAIL %s: %v\n", path, err)
+            failed++
+        case !cpm.Passed(path, out.String()):
+            fmt.Printf("FAIL %s after %d instructions in %v\n", path, m.Instructions, took)
+            failed++
+        default:
+            fmt.Printf("PASS %s, %d instructions in %v\n", path, m.Instructions, took)
+        }
+    }
+    if failed > 0 {
+        return fmt.Errorf("%d of %d failed", failed, fs.NArg())
+    }
+
+    return nil
+}
@@ -0,0 +1,104 @@
+package trace
+
+import (
+    "bufio"
+    "encoding/binary"
+    "errors"
+    "io"
+
+    "github.com/siathema/goInvadeSpace/core"
+)
+
+// Binary traces start with an 8 byte header, "8080TRC" and a version byte,
+// followed by fixed size little endian records:
+//
+//    PC u16, SP u16, opcode [3]u8, A F B C D E H L u8, pad u8, cycles u64
+const (
+    binaryMagic   = "8080TRC"
+    binaryVersion = 1
+    recordSize    = 24
+)
+
+var ErrBadHeader = errors.New("trace: not a binary trace")
+
+// BinaryWriter is a Tracer writing the compact binary format.
+type BinaryWriter struct {
+    w   *bufio.Writer
+    buf [recordSize]byte
+    err error
+}
+
+func NewBinaryWriter(w io.Writer) *BinaryWriter {
+    b := &BinaryWriter{w: bufio.NewWriter(w)}
+    b.w.WriteString(binaryMagic)
+    b.err = b.w.WriteByte(binaryVersion)
+
+    return b
+}
+
+func (b *BinaryWriter) Trace(ev *core.TraceEvent) {
+    if b.err != nil {
+        return
+    }
+    encodeRecord(b.buf[:], ev)
+    _, b.err = b.w.Write(b.buf[:])
+}
+
+func (b *BinaryWriter) Flush() error {
+    if b.err != nil {
+        return b.err
+    }
+
+    return b.w.Flush()
+}
+
+func encodeRecord(buf []byte, ev *core.TraceEvent) {
+    binary.LittleEndian.PutUint16(buf[0:], ev.PC)
+    binary.LittleEndian.PutUint16(buf[2:], ev.SP)
+    copy(buf[4:7], ev.Opcode[:])
+    buf[7], buf[8], buf[9], buf[10] = ev.A, ev.Flags, ev.B, ev.C
+    buf[11], buf[12], buf[13], buf[14] = ev.D, ev.E, ev.H, ev.L
+    buf[15] = 0
+    binary.LittleEndian.PutUint64(buf[16:], ev.Cycles)
+}
+
+func decodeRecord(buf []byte, ev *core.TraceEvent) {
+    ev.PC = binary.LittleEndian.Uint16(buf[0:])
+    ev.SP = binary.LittleEndian.Uint16(buf[2:])
+    copy(ev.Opcode[:], buf[4:7])
+    ev.A, ev.Flags, ev.B, ev.C = buf[7], buf[8], buf[9], buf[10]
+    ev.D, ev.E, ev.H, ev.L = buf[11], buf[12], buf[13], buf[14]
+    ev.Cycles = binary.LittleEndian.Uint64(buf[16:])
+}
+
+// BinaryReader reads back what BinaryWriter wrote.
+type BinaryReader struct {
+    r   *bufio.Reader
+    buf [recordSize]byte
+}
+
+func NewBinaryReader(r io.Reader) (*BinaryReader, error) {
+    b := &BinaryReader{r: bufio.NewReader(r)}
+    header := make([]byte, len(binaryMagic)+1)
+    if _, err := io.ReadFull(b.r, header); err != nil {
+        return nil, ErrBadHeader
+    }
+    if string(header[:len(binaryMagic)]) != binaryMagic || header[len(binaryMagic)] != binaryVersion {
+        return nil, ErrBadHeader
+    }
+
+    return b, nil
+}
+
+// Next fills ev with the next record, returning io.EOF after the last one.
+func (b *BinaryReader) Next(ev *core.TraceEvent) error {
+    if _, err := io.ReadFull(b.r, b.buf[:]); err != nil {
+        if err == io.ErrUnexpectedEOF {
+            return errors.New("trace: truncated record")
+        }
+        return err
+    }
+    decodeRecord(b.buf[:], ev)
+
+    return nil
+}
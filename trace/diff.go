@@ -0,0 +1,329 @@
+package trace
+
+import (
+    "bufio"
+    "bytes"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+
+    "github.com/siathema/goInvadeSpace/core"
+)
+
+// Fields an Entry may carry. A reference trace doesn't have to log all of
+// them, only fields present on both sides get compared.
+const (
+    HasPC = 1 << iota
+    HasOp
+    HasA
+    HasF
+    HasBC
+    HasDE
+    HasHL
+    HasSP
+    HasCycles
+)
+
+// Entry is one instruction read back from a trace.
+type Entry struct {
+    core.TraceEvent
+    Has   uint16
+    OpLen int
+    Line  int
+    Text  string
+}
+
+// EntryReader is implemented by the text and binary trace readers.
+type EntryReader interface {
+    Next(e *Entry) error
+}
+
+// NewReader picks the binary or text reader by looking at the header.
+func NewReader(r io.Reader) (EntryReader, error) {
+    br := bufio.NewReader(r)
+    head, _ := br.Peek(len(binaryMagic))
+    if string(head) == binaryMagic {
+        b, err := NewBinaryReader(br)
+        if err != nil {
+            return nil, err
+        }
+        return &binaryEntries{r: b}, nil
+    }
+
+    return &TextReader{scanner: bufio.NewScanner(br)}, nil
+}
+
+type binaryEntries struct {
+    r *BinaryReader
+    n int
+}
+
+func (b *binaryEntries) Next(e *Entry) error {
+    if err := b.r.Next(&e.TraceEvent); err != nil {
+        return err
+    }
+    b.n++
+    e.Has = HasPC | HasOp | HasA | HasF | HasBC | HasDE | HasHL | HasSP | HasCycles
+    e.OpLen = e.Size()
+    e.Line = b.n
+    e.Text = FormatText(&e.TraceEvent)
+
+    return nil
+}
+
+// TextReader parses text traces. Besides the FormatText layout it accepts
+// KEY:VALUE as well as KEY=VALUE, keys in any case, OPCODE for OP and
+// CYCLES for CYC, so logs from other emulators only need their field names
+// lined up. Blank lines and lines starting with '#' or ';' are skipped.
+type TextReader struct {
+    scanner *bufio.Scanner
+    line    int
+}
+
+func NewTextReader(r io.Reader) *TextReader {
+    return &TextReader{scanner: bufio.NewScanner(r)}
+}
+
+func (t *TextReader) Next(e *Entry) error {
+    for t.scanner.Scan() {
+        t.line++
+        text := strings.TrimSpace(t.scanner.Text())
+        if text == "" || text[0] == '#' || text[0] == ';' {
+            continue
+        }
+        if err := ParseText(text, e); err != nil {
+            return fmt.Errorf("line %d: %v", t.line, err)
+        }
+        e.Line = t.line
+        return nil
+    }
+    if err := t.scanner.Err(); err != nil {
+        return err
+    }
+
+    return io.EOF
+}
+
+// ParseText parses a single trace line into e.
+func ParseText(line string, e *Entry) error {
+    *e = Entry{Text: line}
+    fields, _, _ := strings.Cut(line, ";")
+    for _, field := range strings.Fields(fields) {
+        key, value, found := strings.Cut(field, "=")
+        if !found {
+            key, value, found = strings.Cut(field, ":")
+        }
+        if !found {
+            return fmt.Errorf("bad field %q", field)
+        }
+        key = strings.ToUpper(key)
+        switch key {
+        case "OP", "OPCODE":
+            if err := parseOp(value, e); err != nil {
+                return err
+            }
+            continue
+        case "CYC", "CYCLES":
+            n, err := strconv.ParseUint(value, 10, 64)
+            if err != nil {
+                return fmt.Errorf("bad cycle count %q", value)
+            }
+            e.Cycles = n
+            e.Has |= HasCycles
+            continue
+        case "PC", "A", "F", "BC", "DE", "HL", "SP":
+        default:
+            // Unknown keys are someone else's extra columns, in whatever
+            // format they like, so they're skipped unparsed.
+            continue
+        }
+        v, err := strconv.ParseUint(value, 16, 16)
+        if err != nil {
+            return fmt.Errorf("bad value %q for %s", value, key)
+        }
+        switch key {
+        case "PC":
+            e.PC, e.Has = uint16(v), e.Has|HasPC
+        case "A":
+            e.A, e.Has = uint8(v), e.Has|HasA
+        case "F":
+            e.Flags, e.Has = uint8(v), e.Has|HasF
+        case "BC":
+            e.B, e.C, e.Has = uint8(v>>8), uint8(v), e.Has|HasBC
+        case "DE":
+            e.D, e.E, e.Has = uint8(v>>8), uint8(v), e.Has|HasDE
+        case "HL":
+            e.H, e.L, e.Has = uint8(v>>8), uint8(v), e.Has|HasHL
+        case "SP":
+            e.SP, e.Has = uint16(v), e.Has|HasSP
+        }
+    }
+
+    return nil
+}
+
+func parseOp(value string, e *Entry) error {
+    if len(value)%2 != 0 || len(value) == 0 || len(value) > 6 {
+        return fmt.Errorf("bad opcode %q", value)
+    }
+    for i := 0; i < len(value)/2; i++ {
+        b, err := strconv.ParseUint(value[i*2:i*2+2], 16, 8)
+        if err != nil {
+            return fmt.Errorf("bad opcode %q", value)
+        }
+        e.Opcode[i] = uint8(b)
+    }
+    e.OpLen = len(value) / 2
+    e.Has |= HasOp
+
+    return nil
+}
+
+type DiffOptions struct {
+    // Only these flag bits are compared. Emulators disagree about the
+    // unused bits 1, 3 and 5, so DefaultFlagMask leaves them out.
+    FlagMask uint8
+    // Cycle counts aren't compared when set.
+    IgnoreCycles bool
+    // Instructions shown before the mismatch, and from each trace after
+    // it.
+    Context int
+}
+
+const DefaultFlagMask = 0xD5
+
+func DefaultDiffOptions() DiffOptions {
+    return DiffOptions{FlagMask: DefaultFlagMask, Context: 5}
+}
+
+// Mismatch describes the first instruction where two traces disagree.
+// Index counts instructions from 0. When one trace ends first Fields is
+// empty and the shorter side's entry is nil.
+type Mismatch struct {
+    Index   int
+    Fields  []string
+    Ours    *Entry
+    Ref     *Entry
+    Context []Entry
+    // What each trace goes on to do, up to Context instructions.
+    OursAfter []Entry
+    RefAfter  []Entry
+}
+
+// Diff walks both traces in step and returns the first mismatch, or nil when
+// they agree for as long as both last.
+func Diff(ours, ref EntryReader, opts DiffOptions) (*Mismatch, error) {
+    var context []Entry
+    for i := 0; ; i++ {
+        var o, r Entry
+        errO := ours.Next(&o)
+        errR := ref.Next(&r)
+        if errO != nil && errO != io.EOF {
+            return nil, fmt.Errorf("our trace: %w", errO)
+        }
+        if errR != nil && errR != io.EOF {
+            return nil, fmt.Errorf("reference trace: %w", errR)
+        }
+        var m *Mismatch
+        switch {
+        case errO == io.EOF && errR == io.EOF:
+            return nil, nil
+        case errO == io.EOF:
+            m = &Mismatch{Index: i, Ref: &r, Context: context}
+        case errR == io.EOF:
+            m = &Mismatch{Index: i, Ours: &o, Context: context}
+        default:
+            if fields := compare(&o, &r, opts); len(fields) != 0 {
+                m = &Mismatch{Index: i, Fields: fields, Ours: &o, Ref: &r, Context: context}
+            }
+        }
+        if m != nil {
+            if m.Ours != nil {
+                m.OursAfter = readAhead(ours, opts.Context)
+            }
+            if m.Ref != nil {
+                m.RefAfter = readAhead(ref, opts.Context)
+            }
+            return m, nil
+        }
+        if opts.Context > 0 {
+            if len(context) == opts.Context {
+                context = append(context[:0], context[1:]...)
+            }
+            context = append(context, o)
+        }
+    }
+}
+
+// readAhead reads up to n more entries. The mismatch is already found, so
+// a trace ending or going bad here only cuts the context short.
+func readAhead(r EntryReader, n int) []Entry {
+    var after []Entry
+    for len(after) < n {
+        var e Entry
+        if r.Next(&e) != nil {
+            break
+        }
+        after = append(after, e)
+    }
+
+    return after
+}
+
+func compare(o, r *Entry, opts DiffOptions) []string {
+    both := o.Has & r.Has
+    var fields []string
+    check := func(bit uint16, name string, equal bool) {
+        if both&bit != 0 && !equal {
+            fields = append(fields, name)
+        }
+    }
+    check(HasPC, "PC", o.PC == r.PC)
+    n := o.OpLen
+    if r.OpLen < n {
+        n = r.OpLen
+    }
+    check(HasOp, "OP", bytes.Equal(o.Opcode[:n], r.Opcode[:n]))
+    check(HasA, "A", o.A == r.A)
+    check(HasF, "F", o.Flags&opts.FlagMask == r.Flags&opts.FlagMask)
+    check(HasBC, "BC", o.BC() == r.BC())
+    check(HasDE, "DE", o.DE() == r.DE())
+    check(HasHL, "HL", o.HL() == r.HL())
+    check(HasSP, "SP", o.SP == r.SP)
+    if !opts.IgnoreCycles {
+        check(HasCycles, "CYC", o.Cycles == r.Cycles)
+    }
+
+    return fields
+}
+
+// Report writes a human readable account of the mismatch.
+func (m *Mismatch) Report(w io.Writer) {
+    switch {
+    case m.Ours == nil:
+        fmt.Fprintf(w, "our trace ends after %d instructions, reference continues at line %d\n",
+            m.Index, m.Ref.Line)
+    case m.Ref == nil:
+        fmt.Fprintf(w, "reference trace ends after %d instructions, ours continues at line %d\n",
+            m.Index, m.Ours.Line)
+    default:
+        fmt.Fprintf(w, "first mismatch at instruction %d (%s differ)\n",
+            m.Index, strings.Join(m.Fields, ", "))
+    }
+    for _, e := range m.Context {
+        fmt.Fprintf(w, "      %s\n", e.Text)
+    }
+    if m.Ours != nil {
+        fmt.Fprintf(w, "ours: %s\n", m.Ours.Text)
+        for _, e := range m.OursAfter {
+            fmt.Fprintf(w, "      %s\n", e.Text)
+        }
+    }
+    if m.Ref != nil {
+        fmt.Fprintf(w, "ref:  %s\n", m.Ref.Text)
+        for _, e := range m.RefAfter {
+            fmt.Fprintf(w, "      %s\n", e.Text)
+        }
+    }
+}
@@ -0,0 +1,161 @@
+package trace
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+)
+
+const oursTrace = `PC=0000 OP=0612 A=00 F=02 BC=0000 DE=0000 HL=0000 SP=0000 CYC=0 ; MVI B,$12
+PC=0002 OP=010024 A=00 F=02 BC=1200 DE=0000 HL=0000 SP=0000 CYC=7 ; LXI B,$2400
+PC=0005 OP=00 A=00 F=02 BC=2400 DE=0000 HL=0000 SP=0000 CYC=17 ; NOP
+PC=0006 OP=00 A=00 F=02 BC=2400 DE=0000 HL=0000 SP=0000 CYC=21 ; NOP
+`
+
+func TestParseText(t *testing.T) {
+    var e Entry
+    if err := ParseText("pc:0100 opcode:C3 a:ff f:02 sp:F000 cycles:17 extra:1", &e); err != nil {
+        t.Fatal(err)
+    }
+    if e.PC != 0x0100 || e.A != 0xFF || e.SP != 0xF000 || e.Cycles != 17 ||
+        e.OpLen != 1 || e.Opcode[0] != 0xC3 {
+        t.Errorf("Unexpected entry %+v", e)
+    }
+    if e.Has&HasBC != 0 {
+        t.Errorf("Expected BC to be missing")
+    }
+    if err := ParseText("PC=zz", &e); err == nil {
+        t.Errorf("Expected an error for a bad value")
+    }
+
+    // Other emulators' columns needn't be 16 bit hex.
+    if err := ParseText("PC:0102 SL:123456 PPU:0,21 TOTAL=4294967296 A:01", &e); err != nil {
+        t.Errorf("Expected extra columns to be skipped, got=%v", err)
+    } else if e.PC != 0x0102 || e.A != 0x01 {
+        t.Errorf("Unexpected entry %+v", e)
+    }
+}
+
+func TestDiff(t *testing.T) {
+    tests := []struct {
+        name     string
+        ref      string
+        opts     DiffOptions
+        index    int
+        fields   string
+        matching bool
+    }{
+        {"same", oursTrace, DefaultDiffOptions(), 0, "", true},
+        {
+            // Unused flag bits and missing columns are not compared.
+            "partial",
+            "PC:0000 A:00 F:00\nPC:0002 A:00 F:00\nPC:0005\nPC:0006\n",
+            DefaultDiffOptions(), 0, "", true,
+        },
+        {
+            "register",
+            strings.Replace(oursTrace, "BC=2400 DE=0000 HL=0000 SP=0000 CYC=17", "BC=2401 DE=0000 HL=0000 SP=0000 CYC=17", 1),
+            DefaultDiffOptions(), 2, "BC", false,
+        },
+        {
+            "cycles",
+            strings.Replace(oursTrace, "CYC=21", "CYC=22", 1),
+            DefaultDiffOptions(), 3, "CYC", false,
+        },
+        {
+            "cycles ignored",
+            strings.Replace(oursTrace, "CYC=21", "CYC=22", 1),
+            DiffOptions{FlagMask: DefaultFlagMask, IgnoreCycles: true}, 0, "", true,
+        },
+        {
+            "longer reference",
+            oursTrace + "PC=0007 OP=00\n",
+            DefaultDiffOptions(), 4, "", false,
+        },
+    }
+
+    for _, tt := range tests {
+        m, err := Diff(NewTextReader(strings.NewReader(oursTrace)),
+            NewTextReader(strings.NewReader(tt.ref)), tt.opts)
+        if err != nil {
+            t.Fatalf("%s: %v", tt.name, err)
+        }
+        if tt.matching {
+            if m != nil {
+                t.Errorf("%s: Expected no mismatch, got=%+v", tt.name, m)
+            }
+            continue
+        }
+        if m == nil {
+            t.Errorf("%s: Expected a mismatch", tt.name)
+            continue
+        }
+        if m.Index != tt.index || strings.Join(m.Fields, ",") != tt.fields {
+            t.Errorf("%s: Expected (%d, %q), got=(%d, %q)",
+                tt.name, tt.index, tt.fields, m.Index, strings.Join(m.Fields, ","))
+        }
+    }
+}
+
+func TestDiffBinaryAgainstText(t *testing.T) {
+    bin := &bytes.Buffer{}
+    w := NewBinaryWriter(bin)
+    runProgram(w, testProgram, 3)
+    w.Flush()
+
+    text := &bytes.Buffer{}
+    tw := NewTextWriter(text)
+    runProgram(tw, testProgram, 3)
+    tw.Flush()
+    ref := strings.Replace(text.String(), "PC=0005 OP=00 A=00", "PC=0005 OP=00 A=01", 1)
+
+    ours, err := NewReader(bin)
+    if err != nil {
+        t.Fatal(err)
+    }
+    m, err := Diff(ours, NewTextReader(strings.NewReader(ref)), DiffOptions{FlagMask: 0xFF, Context: 1})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if m == nil || m.Index != 2 || len(m.Context) != 1 {
+        t.Fatalf("Expected mismatch at 2 with 1 line of context, got=%+v", m)
+    }
+
+    // The mismatch is on the last instruction, nothing follows it.
+    if len(m.OursAfter) != 0 || len(m.RefAfter) != 0 {
+        t.Errorf("Expected nothing after the last instruction, got=(%d, %d)", len(m.OursAfter), len(m.RefAfter))
+    }
+
+    out := &bytes.Buffer{}
+    m.Report(out)
+    if !strings.Contains(out.String(), "first mismatch at instruction 2 (A differ)") {
+        t.Errorf("Unexpected report %q", out.String())
+    }
+}
+
+func TestDiffContextAfter(t *testing.T) {
+    ref := strings.Replace(oursTrace, "BC=1200", "BC=1201", 1)
+    ref = strings.Replace(ref, "CYC=17", "CYC=18", 1)
+    m, err := Diff(NewTextReader(strings.NewReader(oursTrace)),
+        NewTextReader(strings.NewReader(ref)), DiffOptions{FlagMask: 0xFF, Context: 1})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if m == nil || m.Index != 1 {
+        t.Fatalf("Expected mismatch at 1, got=%+v", m)
+    }
+    if len(m.OursAfter) != 1 || m.OursAfter[0].PC != 0x0005 || m.OursAfter[0].Cycles != 17 {
+        t.Errorf("Expected our next instruction, got=%+v", m.OursAfter)
+    }
+    if len(m.RefAfter) != 1 || m.RefAfter[0].Cycles != 18 {
+        t.Errorf("Expected the reference's next instruction, got=%+v", m.RefAfter)
+    }
+
+    out := &bytes.Buffer{}
+    m.Report(out)
+    want := "ours: " + strings.Split(oursTrace, "\n")[1] + "\n      " + strings.Split(oursTrace, "\n")[2] + "\n" +
+        "ref:  " + strings.Split(ref, "\n")[1] + "\n      " + strings.Split(ref, "\n")[2] + "\n"
+    if !strings.HasSuffix(out.String(), want) {
+        t.Errorf("Expected report to end with %q, got=%q", want, out.String())
+    }
+}
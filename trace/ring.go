@@ -0,0 +1,58 @@
+package trace
+
+import (
+    "fmt"
+    "io"
+
+    "github.com/siathema/goInvadeSpace/core"
+)
+
+// Ring is a Tracer keeping the last N instructions, cheap enough to leave
+// on all the time and dump when something goes wrong.
+type Ring struct {
+    events []core.TraceEvent
+    next   int
+    full   bool
+}
+
+// NewRing keeps the last n instructions. Any n below 1 keeps 1, like
+// bufio does with sizes that are too small.
+func NewRing(n int) *Ring {
+    if n < 1 {
+        n = 1
+    }
+
+    return &Ring{events: make([]core.TraceEvent, n)}
+}
+
+func (r *Ring) Trace(ev *core.TraceEvent) {
+    r.events[r.next] = *ev
+    r.next++
+    if r.next == len(r.events) {
+        r.next = 0
+        r.full = true
+    }
+}
+
+// Events returns a copy of the recorded instructions, oldest first.
+func (r *Ring) Events() []core.TraceEvent {
+    if !r.full {
+        return append([]core.TraceEvent(nil), r.events[:r.next]...)
+    }
+    out := make([]core.TraceEvent, 0, len(r.events))
+    out = append(out, r.events[r.next:]...)
+
+    return append(out, r.events[:r.next]...)
+}
+
+// Dump writes the recorded instructions as text trace lines.
+func (r *Ring) Dump(w io.Writer, syms core.Symbolizer) error {
+    for _, ev := range r.Events() {
+        text, _ := core.DisassembleSym(ev.Opcode[:], syms)
+        if _, err := fmt.Fprintf(w, "%s ; %s\n", FormatText(&ev), text); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
@@ -0,0 +1,65 @@
+package trace
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "strings"
+
+    "github.com/siathema/goInvadeSpace/core"
+)
+
+// Text traces are one line per instruction, state before it executes:
+//
+//    PC=18D4 OP=310024 A=00 F=02 BC=0000 DE=0000 HL=0000 SP=0000 CYC=10 ; LXI SP,$2400
+//
+// Values are hex except CYC, the decimal count of cycles run before the
+// instruction. OP holds only the instruction's own bytes. Anything after
+// ';' is a comment, the writer puts the disassembly there.
+func FormatText(ev *core.TraceEvent) string {
+    var sb strings.Builder
+    fmt.Fprintf(&sb, "PC=%04X OP=", ev.PC)
+    for i := 0; i < ev.Size(); i++ {
+        fmt.Fprintf(&sb, "%02X", ev.Opcode[i])
+    }
+    fmt.Fprintf(&sb, " A=%02X F=%02X BC=%04X DE=%04X HL=%04X SP=%04X CYC=%d",
+        ev.A, ev.Flags, ev.BC(), ev.DE(), ev.HL(), ev.SP, ev.Cycles)
+
+    return sb.String()
+}
+
+// TextWriter is a Tracer writing FormatText lines.
+type TextWriter struct {
+    // Disasm appends the disassembly of each instruction as a comment,
+    // naming addresses through Syms when it is set.
+    Disasm bool
+    Syms   core.Symbolizer
+
+    w   *bufio.Writer
+    err error
+}
+
+func NewTextWriter(w io.Writer) *TextWriter {
+    return &TextWriter{w: bufio.NewWriter(w), Disasm: true}
+}
+
+func (t *TextWriter) Trace(ev *core.TraceEvent) {
+    if t.err != nil {
+        return
+    }
+    line := FormatText(ev)
+    if t.Disasm {
+        text, _ := core.DisassembleSym(ev.Opcode[:], t.Syms)
+        line += " ; " + text
+    }
+    _, t.err = fmt.Fprintln(t.w, line)
+}
+
+// Flush writes out buffered lines and returns the first error seen.
+func (t *TextWriter) Flush() error {
+    if t.err != nil {
+        return t.err
+    }
+
+    return t.w.Flush()
+}
@@ -0,0 +1,120 @@
+package trace
+
+import (
+    "bytes"
+    "io"
+    "strings"
+    "testing"
+
+    "github.com/siathema/goInvadeSpace/core"
+    "github.com/siathema/goInvadeSpace/memory"
+)
+
+// runProgram executes n instructions of program with t attached.
+func runProgram(t core.Tracer, program []uint8, n int) *core.Core8080 {
+    rom := make([]uint8, memory.Kilobytes(8))
+    copy(rom, program)
+    mem := memory.NewMainMemory(rom)
+    c := core.New()
+    c.Tracer = t
+    for i := 0; i < n; i++ {
+        c.RunTick(mem)
+    }
+
+    return c
+}
+
+// MVI B,12; LXI B,2400; NOP
+var testProgram = []uint8{0x06, 0x12, 0x01, 0x00, 0x24, 0x00}
+
+func TestTextWriter(t *testing.T) {
+    out := &bytes.Buffer{}
+    w := NewTextWriter(out)
+    runProgram(w, testProgram, 3)
+    if err := w.Flush(); err != nil {
+        t.Fatal(err)
+    }
+
+    expected := []string{
+        "PC=0000 OP=0612 A=00 F=02 BC=0000 DE=0000 HL=0000 SP=0000 CYC=0 ; MVI B,$12",
+        "PC=0002 OP=010024 A=00 F=02 BC=1200 DE=0000 HL=0000 SP=0000 CYC=7 ; LXI B,$2400",
+        "PC=0005 OP=00 A=00 F=02 BC=2400 DE=0000 HL=0000 SP=0000 CYC=17 ; NOP",
+    }
+    lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+    if len(lines) != len(expected) {
+        t.Fatalf("Expected %d lines, got=%q", len(expected), lines)
+    }
+    for i := range expected {
+        if lines[i] != expected[i] {
+            t.Errorf("Expected %q, got=%q", expected[i], lines[i])
+        }
+    }
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+    ring := NewRing(8)
+    out := &bytes.Buffer{}
+    w := NewBinaryWriter(out)
+    runProgram(multi{ring, w}, testProgram, 3)
+    if err := w.Flush(); err != nil {
+        t.Fatal(err)
+    }
+    if out.Len() != 8+3*recordSize {
+        t.Errorf("Expected %d bytes, got=%d", 8+3*recordSize, out.Len())
+    }
+
+    r, err := NewBinaryReader(out)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var ev core.TraceEvent
+    for i, want := range ring.Events() {
+        if err := r.Next(&ev); err != nil {
+            t.Fatal(err)
+        }
+        if ev != want {
+            t.Errorf("Expected record %d=%+v, got=%+v", i, want, ev)
+        }
+    }
+    if err := r.Next(&ev); err != io.EOF {
+        t.Errorf("Expected io.EOF, got=%v", err)
+    }
+
+    if _, err := NewBinaryReader(strings.NewReader("PC=0000")); err != ErrBadHeader {
+        t.Errorf("Expected ErrBadHeader, got=%v", err)
+    }
+}
+
+func TestRing(t *testing.T) {
+    ring := NewRing(2)
+    runProgram(ring, testProgram, 3)
+
+    events := ring.Events()
+    if len(events) != 2 || events[0].PC != 0x0002 || events[1].PC != 0x0005 {
+        t.Errorf("Expected the last two instructions, got=%+v", events)
+    }
+
+    out := &bytes.Buffer{}
+    ring.Dump(out, nil)
+    if !strings.HasSuffix(out.String(), "; NOP\n") {
+        t.Errorf("Expected dump to end with the NOP, got=%q", out.String())
+    }
+}
+
+func TestRingTooSmall(t *testing.T) {
+    for _, n := range []int{0, -1} {
+        ring := NewRing(n)
+        runProgram(ring, testProgram, 3)
+        if events := ring.Events(); len(events) != 1 || events[0].PC != 0x0005 {
+            t.Errorf("NewRing(%d): expected only the last instruction, got=%+v", n, events)
+        }
+    }
+}
+
+type multi []core.Tracer
+
+func (m multi) Trace(ev *core.TraceEvent) {
+    for _, t := range m {
+        t.Trace(ev)
+    }
+}
@@ -0,0 +1,45 @@
+package web
+
+// The page shows /stream under the cabinet's colour overlay and sends key
+// presses down /ws.
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Space Invaders</title>
+<style>
+  body { background: #000; color: #aaa; font: 14px monospace; text-align: center; }
+  #screen { position: relative; display: inline-block; margin-top: 16px; }
+  #screen img { display: block; width: 448px; height: 512px; image-rendering: pixelated; }
+  #overlay { position: absolute; inset: 0; mix-blend-mode: multiply; background: linear-gradient(
+      #fff 0 12.5%, #f33 12.5% 25%, #fff 25% 71.875%, #3f3 71.875% 93.75%, #fff 93.75%); }
+</style>
+</head>
+<body>
+<div id="screen"><img src="/stream" alt="screen"><div id="overlay"></div></div>
+<p>C coin &middot; 1/2 start &middot; arrows move &middot; space fire &middot; T tilt &middot; R rewind</p>
+<p id="status">connecting</p>
+<script>
+const keys = {
+  "c": "coin", "1": "start1", "2": "start2", " ": "fire",
+  "ArrowLeft": "left", "ArrowRight": "right", "t": "tilt", "r": "rewind",
+};
+const status = document.getElementById("status");
+const ws = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/ws");
+ws.onopen = () => status.textContent = "connected";
+ws.onclose = () => status.textContent = "disconnected";
+ws.onmessage = (e) => status.textContent = e.data;
+function send(action, e) {
+  const name = keys[e.key.length === 1 ? e.key.toLowerCase() : e.key];
+  if (!name || e.repeat || ws.readyState !== WebSocket.OPEN) {
+    return;
+  }
+  e.preventDefault();
+  ws.send(action + " " + name);
+}
+document.addEventListener("keydown", (e) => send("down", e));
+document.addEventListener("keyup", (e) => send("up", e));
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,270 @@
+// Package web serves a running machine over HTTP: a page to play it on, a
+// stream of frames and a WebSocket taking the controls.
+package web
+
+import (
+    "fmt"
+    "image"
+    "image/jpeg"
+    "image/png"
+    "io"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/siathema/goInvadeSpace/machine"
+)
+
+// Server runs a machine at 60 frames a second and shares it with every
+// browser that connects. It's one machine, so every socket plays the same
+// game, but each holds its own buttons: a button is down while any socket,
+// or Press, holds it. Sockets are only taken from pages served here.
+//
+//    GET /            the page
+//    GET /frame.png   the current frame
+//    GET /stream      frames as MJPEG, multipart/x-mixed-replace
+//    GET /ws          a WebSocket taking "down NAME" and "up NAME" text
+//                     messages for the buttons in Buttons
+type Server struct {
+    // Frames a second sent down /stream.
+    StreamFPS int
+    // JPEG quality of /stream, 1 to 100.
+    Quality int
+    // Speed of Run relative to the real machine, 0 for as fast as possible.
+    Speed float64
+    // Rewind records play for RewindButton to step back through, nil turns
+    // it off.
+    Rewind *machine.Rewind
+
+    mu      sync.Mutex
+    m       *machine.Machine
+    screen  *image.Gray
+    pressed machine.Input
+    sockets map[*wsConn]machine.Input
+    // input is pressed and every socket's buttons together.
+    input machine.Input
+}
+
+// Buttons are the names the WebSocket takes.
+var Buttons = map[string]machine.Input{
+    "coin":   machine.Coin,
+    "start1": machine.P1Start,
+    "start2": machine.P2Start,
+    "fire":   machine.P1Fire,
+    "left":   machine.P1Left,
+    "right":  machine.P1Right,
+    "fire2":  machine.P2Fire,
+    "left2":  machine.P2Left,
+    "right2": machine.P2Right,
+    "tilt":   machine.Tilt,
+    "rewind": RewindButton,
+}
+
+// RewindButton steps back through Rewind a capture a frame while it's held.
+// It's a bit machine.Input doesn't use, so it's pressed like the controls.
+const RewindButton machine.Input = 1 << 15
+
+func New(m *machine.Machine) *Server {
+    return &Server{
+        StreamFPS: 30,
+        Quality:   80,
+        Speed:     1,
+        Rewind:    machine.NewRewind(machine.RewindInterval, machine.RewindBudget),
+        m:         m,
+        screen:    image.NewGray(image.Rect(0, 0, machine.ScreenWidth, machine.ScreenHeight)),
+        sockets:   make(map[*wsConn]machine.Input),
+    }
+}
+
+// Handler routes the endpoints above.
+func (s *Server) Handler() http.Handler {
+    mux := http.NewServeMux()
+    mux.HandleFunc("/", s.servePage)
+    mux.HandleFunc("/frame.png", s.serveFrame)
+    mux.HandleFunc("/stream", s.serveStream)
+    mux.HandleFunc("/ws", s.serveWS)
+
+    return mux
+}
+
+// ListenAndServe runs the machine and serves it on addr until the server
+// fails.
+func (s *Server) ListenAndServe(addr string) error {
+    stop := make(chan struct{})
+    defer close(stop)
+    go s.Run(stop)
+
+    return http.ListenAndServe(addr, s.Handler())
+}
+
+// Run plays frames at Speed until stop is closed.
+func (s *Server) Run(stop <-chan struct{}) {
+    var tick <-chan time.Time
+    if s.Speed > 0 {
+        t := time.NewTicker(time.Duration(float64(time.Second) / machine.FrameRate / s.Speed))
+        defer t.Stop()
+        tick = t.C
+    }
+    for {
+        select {
+        case <-stop:
+            return
+        default:
+        }
+        if tick != nil {
+            select {
+            case <-stop:
+                return
+            case <-tick:
+            }
+        }
+        s.RunFrame()
+    }
+}
+
+// RunFrame runs one frame with the buttons currently held, or steps back
+// one capture while RewindButton is.
+func (s *Server) RunFrame() {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.input&RewindButton != 0 && s.Rewind != nil {
+        s.Rewind.Back(s.m)
+        return
+    }
+    s.m.Ports.Input = s.input &^ RewindButton
+    s.m.RunFrame()
+    if s.Rewind != nil {
+        s.Rewind.Capture(s.m)
+    }
+}
+
+// Press holds a button down or lets it go, leaving alone what the sockets
+// hold.
+func (s *Server) Press(in machine.Input, down bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.pressed = press(s.pressed, in, down)
+    s.update()
+}
+
+// hold sets the buttons one socket holds, leave drops it.
+func (s *Server) hold(c *wsConn, in machine.Input, down bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.sockets[c] = press(s.sockets[c], in, down)
+    s.update()
+}
+
+func (s *Server) leave(c *wsConn) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    delete(s.sockets, c)
+    s.update()
+}
+
+// update works out input again, with mu held.
+func (s *Server) update() {
+    s.input = s.pressed
+    for _, in := range s.sockets {
+        s.input |= in
+    }
+}
+
+func press(held, in machine.Input, down bool) machine.Input {
+    if down {
+        return held | in
+    }
+
+    return held &^ in
+}
+
+// snapshot copies the current picture out from under the lock.
+func (s *Server) snapshot() *image.Gray {
+    s.mu.Lock()
+    machine.RenderScreen(s.m.VRAM(), s.screen)
+    img := image.NewGray(s.screen.Rect)
+    copy(img.Pix, s.screen.Pix)
+    s.mu.Unlock()
+
+    return img
+}
+
+func (s *Server) servePage(w http.ResponseWriter, r *http.Request) {
+    if r.URL.Path != "/" {
+        http.NotFound(w, r)
+        return
+    }
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    io.WriteString(w, page)
+}
+
+func (s *Server) serveFrame(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "image/png")
+    w.Header().Set("Cache-Control", "no-store")
+    png.Encode(w, s.snapshot())
+}
+
+const streamBoundary = "frame"
+
+func (s *Server) serveStream(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+streamBoundary)
+    w.Header().Set("Cache-Control", "no-store")
+    flusher, _ := w.(http.Flusher)
+    fps := s.StreamFPS
+    if fps <= 0 {
+        fps = 30
+    }
+    tick := time.NewTicker(time.Second / time.Duration(fps))
+    defer tick.Stop()
+    var buf strings.Builder
+    for {
+        buf.Reset()
+        if err := jpeg.Encode(&buf, s.snapshot(), &jpeg.Options{Quality: s.Quality}); err != nil {
+            return
+        }
+        _, err := fmt.Fprintf(w, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n%s\r\n",
+            streamBoundary, buf.Len(), buf.String())
+        if err != nil {
+            return
+        }
+        if flusher != nil {
+            flusher.Flush()
+        }
+        select {
+        case <-r.Context().Done():
+            return
+        case <-tick.C:
+        }
+    }
+}
+
+func (s *Server) serveWS(w http.ResponseWriter, r *http.Request) {
+    if !sameOrigin(r) {
+        http.Error(w, "websocket from another site", http.StatusForbidden)
+        return
+    }
+    c, err := wsUpgrade(w, r)
+    if err != nil {
+        return
+    }
+    defer c.Close()
+    // Whatever this browser holds is let go when it leaves.
+    defer s.leave(c)
+    for {
+        op, msg, err := c.ReadMessage()
+        if err != nil {
+            return
+        }
+        if op != wsText {
+            continue
+        }
+        action, name, _ := strings.Cut(string(msg), " ")
+        in, ok := Buttons[name]
+        if !ok || (action != "down" && action != "up") {
+            c.WriteMessage(wsText, []byte("error: expected down|up NAME"))
+            continue
+        }
+        s.hold(c, in, action == "down")
+    }
+}
@@ -0,0 +1,249 @@
+package web
+
+import (
+    "bufio"
+    "image/jpeg"
+    "image/png"
+    "io"
+    "mime"
+    "mime/multipart"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/siathema/goInvadeSpace/machine"
+    "github.com/siathema/goInvadeSpace/memory"
+)
+
+func newTestServer(t *testing.T) (*Server, *httptest.Server) {
+    s := New(machine.New(make([]uint8, memory.Kilobytes(8))))
+    ts := httptest.NewServer(s.Handler())
+    t.Cleanup(ts.Close)
+
+    return s, ts
+}
+
+func TestFrames(t *testing.T) {
+    s, ts := newTestServer(t)
+    s.m.Mem.Ram[machine.VRAMStart-0x2000+31] = 0x80
+    s.RunFrame()
+
+    res, err := http.Get(ts.URL + "/frame.png")
+    if err != nil {
+        t.Fatal(err)
+    }
+    img, err := png.Decode(res.Body)
+    res.Body.Close()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if b := img.Bounds(); b.Dx() != machine.ScreenWidth || b.Dy() != machine.ScreenHeight {
+        t.Errorf("Expected a 224x256 frame, got=%v", b)
+    }
+    if r, _, _, _ := img.At(0, 0).RGBA(); r == 0 {
+        t.Errorf("Expected the top left pixel lit")
+    }
+
+    res, err = http.Get(ts.URL + "/stream")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer res.Body.Close()
+    _, params, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    part, err := multipart.NewReader(res.Body, params["boundary"]).NextPart()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := jpeg.Decode(part); err != nil {
+        t.Errorf("Expected a JPEG in the stream, got=%v", err)
+    }
+
+    res, err = http.Get(ts.URL + "/")
+    if err != nil {
+        t.Fatal(err)
+    }
+    body, _ := io.ReadAll(res.Body)
+    res.Body.Close()
+    if !strings.Contains(string(body), `src="/stream"`) {
+        t.Errorf("Expected the page to show the stream")
+    }
+}
+
+func TestWSAccept(t *testing.T) {
+    // The example from RFC 6455.
+    if got := wsAccept("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+        t.Errorf("Expected s3pPLMBiTxaQ9kYGzzhZRbK+xOo=, got=%s", got)
+    }
+}
+
+// clientFrame builds a masked frame as a browser would send it.
+func clientFrame(fin bool, op byte, payload string) []byte {
+    b0 := op
+    if fin {
+        b0 |= 0x80
+    }
+    mask := []byte{1, 2, 3, 4}
+    f := append([]byte{b0, 0x80 | byte(len(payload))}, mask...)
+    for i := range payload {
+        f = append(f, payload[i]^mask[i%4])
+    }
+    return f
+}
+
+func (s *Server) held() machine.Input {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.input
+}
+
+// waitInput waits for the server to catch up with the socket.
+func waitInput(t *testing.T, s *Server, want machine.Input) {
+    for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); {
+        if s.held() == want {
+            return
+        }
+        time.Sleep(time.Millisecond)
+    }
+    t.Fatalf("Expected input %04X, got=%04X", want, s.held())
+}
+
+// dialWS sends a handshake for /ws with the extra header lines given.
+func dialWS(t *testing.T, ts *httptest.Server, header string) (net.Conn, *bufio.Reader, *http.Response) {
+    conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { conn.Close() })
+    io.WriteString(conn, "GET /ws HTTP/1.1\r\nHost: x\r\nConnection: Upgrade\r\n"+
+        "Upgrade: websocket\r\nSec-WebSocket-Version: 13\r\n"+
+        "Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+header+"\r\n")
+    r := bufio.NewReader(conn)
+    res, err := http.ReadResponse(r, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    return conn, r, res
+}
+
+func TestWebSocket(t *testing.T) {
+    s, ts := newTestServer(t)
+    conn, r, res := dialWS(t, ts, "")
+    if res.StatusCode != http.StatusSwitchingProtocols ||
+        res.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+        t.Fatalf("Expected a 101 with the accept key, got=%s %v", res.Status, res.Header)
+    }
+
+    conn.Write(clientFrame(true, wsText, "down fire"))
+    // A message in two fragments.
+    conn.Write(clientFrame(false, wsText, "down "))
+    conn.Write(clientFrame(true, wsContinuation, "left"))
+    waitInput(t, s, machine.P1Fire|machine.P1Left)
+    conn.Write(clientFrame(true, wsText, "up fire"))
+    waitInput(t, s, machine.P1Left)
+
+    conn.Write(clientFrame(true, wsPing, "hi"))
+    conn.Write(clientFrame(true, wsText, "down nothing"))
+    want := []struct {
+        op  byte
+        msg string
+    }{{wsPong, "hi"}, {wsText, "error: expected down|up NAME"}}
+    for _, w := range want {
+        head := make([]byte, 2)
+        io.ReadFull(r, head)
+        msg := make([]byte, head[1])
+        io.ReadFull(r, msg)
+        if head[0] != 0x80|w.op || string(msg) != w.msg {
+            t.Errorf("Expected frame %X %q, got=%X %q", w.op, w.msg, head[0], msg)
+        }
+    }
+
+    // Buttons still held are let go when the socket closes.
+    conn.Write(clientFrame(true, wsClose, ""))
+    waitInput(t, s, 0)
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+    _, ts := newTestServer(t)
+    for _, tt := range []struct {
+        origin string
+        status int
+    }{
+        {"http://x", http.StatusSwitchingProtocols},
+        {"http://X", http.StatusSwitchingProtocols},
+        {"https://evil.example", http.StatusForbidden},
+        {"http://x.evil.example", http.StatusForbidden},
+        {"null", http.StatusForbidden},
+    } {
+        _, _, res := dialWS(t, ts, "Origin: "+tt.origin+"\r\n")
+        if res.StatusCode != tt.status {
+            t.Errorf("Origin %s: expected %d, got=%d", tt.origin, tt.status, res.StatusCode)
+        }
+    }
+}
+
+// One tab letting go of a button mustn't let go of it for another.
+func TestWebSocketsHoldTheirOwn(t *testing.T) {
+    s, ts := newTestServer(t)
+    a, _, _ := dialWS(t, ts, "")
+    b, _, _ := dialWS(t, ts, "")
+
+    a.Write(clientFrame(true, wsText, "down fire"))
+    b.Write(clientFrame(true, wsText, "down fire"))
+    b.Write(clientFrame(true, wsText, "down left"))
+    waitInput(t, s, machine.P1Fire|machine.P1Left)
+    a.Write(clientFrame(true, wsText, "up fire"))
+    a.Write(clientFrame(true, wsText, "up left"))
+    // Give a's messages time to be handled before checking nothing changed.
+    a.Write(clientFrame(true, wsText, "down coin"))
+    waitInput(t, s, machine.P1Fire|machine.P1Left|machine.Coin)
+
+    s.Press(machine.P1Right, true)
+    a.Write(clientFrame(true, wsClose, ""))
+    waitInput(t, s, machine.P1Fire|machine.P1Left|machine.P1Right)
+    b.Write(clientFrame(true, wsClose, ""))
+    waitInput(t, s, machine.P1Right)
+}
+
+func TestRunSpeed(t *testing.T) {
+    s, _ := newTestServer(t)
+    s.Speed = 0
+    stop := make(chan struct{})
+    done := make(chan struct{})
+    go func() {
+        s.Run(stop)
+        close(done)
+    }()
+    // Real time is 6 frames in 100ms, flat out is many more even with the
+    // race detector on.
+    time.Sleep(100 * time.Millisecond)
+    close(stop)
+    <-done
+    if s.m.Frame < 12 {
+        t.Errorf("Expected Speed 0 to run flat out, got=%d frames in 100ms", s.m.Frame)
+    }
+}
+
+func TestRewindButton(t *testing.T) {
+    s, _ := newTestServer(t)
+    for i := 0; i < 20; i++ {
+        s.RunFrame()
+    }
+    s.Press(RewindButton, true)
+    s.RunFrame()
+    s.RunFrame()
+    if s.m.Frame != 16 {
+        t.Errorf("Expected rewind to step back to frame 16, got=%d", s.m.Frame)
+    }
+    s.Press(RewindButton, false)
+    s.RunFrame()
+    if s.m.Frame != 17 || s.m.Ports.Input&RewindButton != 0 {
+        t.Errorf("Expected play to carry on without the button reaching the ports, got frame %d", s.m.Frame)
+    }
+}
@@ -0,0 +1,213 @@
+package web
+
+import (
+    "bufio"
+    "crypto/sha1"
+    "encoding/base64"
+    "encoding/binary"
+    "errors"
+    "io"
+    "net"
+    "net/http"
+    "net/url"
+    "strings"
+)
+
+// Just enough of RFC 6455 for the page to send key presses: the handshake,
+// masked frames from the client, fragmented messages, ping and close.
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Frame opcodes.
+const (
+    wsContinuation = 0x0
+    wsText         = 0x1
+    wsBinary       = 0x2
+    wsClose        = 0x8
+    wsPing         = 0x9
+    wsPong         = 0xa
+)
+
+// Messages past this are refused, key presses are a few bytes.
+const wsMaxMessage = 1 << 16
+
+var errWSProtocol = errors.New("web: websocket protocol error")
+
+type wsConn struct {
+    conn net.Conn
+    rw   *bufio.ReadWriter
+}
+
+// wsAccept is the Sec-WebSocket-Accept answer to a client's key.
+func wsAccept(key string) string {
+    sum := sha1.Sum([]byte(key + wsGUID))
+    return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func headerHas(h http.Header, name, token string) bool {
+    for _, v := range h.Values(name) {
+        for _, part := range strings.Split(v, ",") {
+            if strings.EqualFold(strings.TrimSpace(part), token) {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// sameOrigin is whether the page opening the socket came from this server.
+// Browsers send Origin with every handshake, so without the check any site
+// open in the browser could play through localhost. Other clients may leave
+// it out and are let in.
+func sameOrigin(r *http.Request) bool {
+    origin := r.Header.Get("Origin")
+    if origin == "" {
+        return true
+    }
+    u, err := url.Parse(origin)
+    if err != nil {
+        return false
+    }
+
+    return strings.EqualFold(u.Host, r.Host)
+}
+
+// wsUpgrade completes the opening handshake and takes over the connection.
+func wsUpgrade(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+    key := r.Header.Get("Sec-WebSocket-Key")
+    if r.Method != http.MethodGet || key == "" ||
+        !headerHas(r.Header, "Connection", "upgrade") ||
+        !headerHas(r.Header, "Upgrade", "websocket") {
+        http.Error(w, "expected a websocket handshake", http.StatusBadRequest)
+        return nil, errWSProtocol
+    }
+    hj, ok := w.(http.Hijacker)
+    if !ok {
+        http.Error(w, "can't hijack the connection", http.StatusInternalServerError)
+        return nil, errors.New("web: connection can't be hijacked")
+    }
+    conn, rw, err := hj.Hijack()
+    if err != nil {
+        return nil, err
+    }
+    rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+        "Upgrade: websocket\r\n" +
+        "Connection: Upgrade\r\n" +
+        "Sec-WebSocket-Accept: " + wsAccept(key) + "\r\n\r\n")
+    if err := rw.Flush(); err != nil {
+        conn.Close()
+        return nil, err
+    }
+
+    return &wsConn{conn: conn, rw: rw}, nil
+}
+
+func (c *wsConn) Close() error {
+    return c.conn.Close()
+}
+
+// readFrame reads one frame, unmasking its payload.
+func (c *wsConn) readFrame() (fin bool, op byte, payload []byte, err error) {
+    var head [2]byte
+    if _, err = io.ReadFull(c.rw, head[:]); err != nil {
+        return
+    }
+    fin, op = head[0]&0x80 != 0, head[0]&0x0f
+    masked := head[1]&0x80 != 0
+    size := uint64(head[1] & 0x7f)
+    switch size {
+    case 126:
+        var ext [2]byte
+        if _, err = io.ReadFull(c.rw, ext[:]); err != nil {
+            return
+        }
+        size = uint64(binary.BigEndian.Uint16(ext[:]))
+    case 127:
+        var ext [8]byte
+        if _, err = io.ReadFull(c.rw, ext[:]); err != nil {
+            return
+        }
+        size = binary.BigEndian.Uint64(ext[:])
+    }
+    // Clients must mask what they send.
+    if !masked || size > wsMaxMessage {
+        err = errWSProtocol
+        return
+    }
+    var mask [4]byte
+    if _, err = io.ReadFull(c.rw, mask[:]); err != nil {
+        return
+    }
+    payload = make([]byte, size)
+    if _, err = io.ReadFull(c.rw, payload); err != nil {
+        return
+    }
+    for i := range payload {
+        payload[i] ^= mask[i%4]
+    }
+
+    return
+}
+
+// ReadMessage returns the next text or binary message, answering pings
+// on the way. A close from the client is answered and gives io.EOF.
+func (c *wsConn) ReadMessage() (byte, []byte, error) {
+    var op byte
+    var msg []byte
+    for {
+        fin, frameOp, payload, err := c.readFrame()
+        if err != nil {
+            return 0, nil, err
+        }
+        switch frameOp {
+        case wsPing:
+            if err := c.WriteMessage(wsPong, payload); err != nil {
+                return 0, nil, err
+            }
+            continue
+        case wsPong:
+            continue
+        case wsClose:
+            c.WriteMessage(wsClose, payload)
+            return 0, nil, io.EOF
+        case wsText, wsBinary:
+            if msg != nil {
+                return 0, nil, errWSProtocol
+            }
+            op = frameOp
+            msg = payload
+        case wsContinuation:
+            if msg == nil {
+                return 0, nil, errWSProtocol
+            }
+            msg = append(msg, payload...)
+        default:
+            return 0, nil, errWSProtocol
+        }
+        if len(msg) > wsMaxMessage {
+            return 0, nil, errWSProtocol
+        }
+        if fin {
+            return op, msg, nil
+        }
+    }
+}
+
+// WriteMessage sends payload as one unmasked frame.
+func (c *wsConn) WriteMessage(op byte, payload []byte) error {
+    head := []byte{0x80 | op}
+    switch n := len(payload); {
+    case n < 126:
+        head = append(head, byte(n))
+    case n <= 0xffff:
+        head = append(head, 126)
+        head = binary.BigEndian.AppendUint16(head, uint16(n))
+    default:
+        head = append(head, 127)
+        head = binary.BigEndian.AppendUint64(head, uint64(n))
+    }
+    c.rw.Write(head)
+    c.rw.Write(payload)
+
+    return c.rw.Flush()
+}